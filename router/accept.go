@@ -0,0 +1,120 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptSpec is one element of an Accept-style header: a value with its
+// quality and any other parameters.
+type acceptSpec struct {
+	value  string
+	q      float64
+	params map[string]string
+}
+
+// parseAcceptHeader splits an Accept, Accept-Encoding or Accept-Language
+// header into its elements in the order they were sent. Elements with a
+// malformed q-value are treated as q=1; values are lower-cased.
+func parseAcceptHeader(header string) []acceptSpec {
+	var specs []acceptSpec
+	for _, element := range strings.Split(header, ",") {
+		parts := strings.Split(element, ";")
+		value := strings.ToLower(strings.TrimSpace(parts[0]))
+		if value == "" {
+			continue
+		}
+
+		spec := acceptSpec{value: value, q: 1}
+		for _, param := range parts[1:] {
+			name, val, _ := strings.Cut(param, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			val = strings.Trim(strings.TrimSpace(val), `"`)
+			if name == "q" {
+				if q, err := strconv.ParseFloat(val, 64); err == nil && q >= 0 && q <= 1 {
+					spec.q = q
+				}
+				continue
+			}
+			if spec.params == nil {
+				spec.params = make(map[string]string)
+			}
+			spec.params[name] = val
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// NegotiateContentType returns the offered media type that best matches the
+// Accept header of r, or "" when none is acceptable. Offers are ranked by the
+// quality of the most specific media range matching them, then by how
+// specific that range is, then by the order of offers. A request without an
+// Accept header accepts the first offer.
+func NegotiateContentType(r *http.Request, offers []string) string {
+	header := r.Header.Get("Accept")
+	if strings.TrimSpace(header) == "" {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+	ranges := parseAcceptHeader(header)
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+	for _, offer := range offers {
+		q, specificity := mediaRangeQuality(ranges, strings.ToLower(offer))
+		if q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+	return best
+}
+
+// mediaRangeQuality finds the most specific range matching offer and returns
+// its quality and specificity: 0 for */*, 1 for type/* and 2 plus the number
+// of parameters for type/subtype. Parameters are not required to match.
+func mediaRangeQuality(ranges []acceptSpec, offer string) (float64, int) {
+	offerType, offerSubtype, _ := strings.Cut(offer, "/")
+
+	q, specificity := 0.0, -1
+	for _, ar := range ranges {
+		rangeType, rangeSubtype, _ := strings.Cut(ar.value, "/")
+		s := -1
+		switch {
+		case rangeType == "*" && rangeSubtype == "*":
+			s = 0
+		case rangeType == offerType && rangeSubtype == "*":
+			s = 1
+		case rangeType == offerType && rangeSubtype == offerSubtype:
+			s = 2 + len(ar.params)
+		}
+		if s > specificity {
+			q, specificity = ar.q, s
+		}
+	}
+	return q, specificity
+}
+
+// NegotiatedContentType returns the media type chosen for r by
+// ContentNegotiationMiddleware, or "" when the middleware did not run.
+func NegotiatedContentType(r *http.Request) string {
+	contentType, _ := r.Context().Value(contentTypeKey).(string)
+	return contentType
+}
+
+// addVary adds field to the Vary header unless it is already listed.
+func addVary(h http.Header, field string) {
+	for _, value := range h.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
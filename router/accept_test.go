@@ -0,0 +1,110 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseAcceptHeader(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []acceptSpec
+	}{
+		{"", nil},
+		{"Text/HTML", []acceptSpec{{value: "text/html", q: 1}}},
+		{
+			`text/html;level=1, application/json; q=0.5 ,*/*;q="0.1"`,
+			[]acceptSpec{
+				{value: "text/html", q: 1, params: map[string]string{"level": "1"}},
+				{value: "application/json", q: 0.5},
+				{value: "*/*", q: 0.1},
+			},
+		},
+		{"gzip;q=0, br;q=1.5, deflate;q=x", []acceptSpec{{value: "gzip", q: 0}, {value: "br", q: 1}, {value: "deflate", q: 1}}},
+		{" , ;q=0.5, en", []acceptSpec{{value: "en", q: 1}}},
+	}
+
+	for _, tt := range tests {
+		if got := parseAcceptHeader(tt.header); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseAcceptHeader(%q) = %+v, want %+v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "text/plain"}
+	tests := []struct {
+		accept string
+		offers []string
+		want   string
+	}{
+		{"", offers, "application/json"},
+		{"", nil, ""},
+		{"application/xml", offers, "application/xml"},
+		{"Application/XML", offers, "application/xml"},
+		{"*/*", offers, "application/json"},
+		{"text/*;q=0.5, application/json;q=0.4", offers, "text/plain"},
+		{"*/*;q=0.1, application/json;q=0", offers, "application/xml"},
+		// Equal quality goes to the more specific range, then the first offer.
+		{"application/*;q=0.8, application/xml;q=0.8", offers, "application/xml"},
+		{"application/*", offers, "application/json"},
+		// A malformed q-value counts as 1.
+		{"application/json;q=0.9, application/xml;q=2", offers, "application/xml"},
+		{"text/plain;format=flowed;q=0.2, text/*;q=0.9, */*;q=0.5", offers, "application/json"},
+		{"image/png", offers, ""},
+		{"*/*;q=0", offers, ""},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := NegotiateContentType(r, tt.offers); got != tt.want {
+			t.Errorf("Accept %q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestContentNegotiationMiddleware(t *testing.T) {
+	h := ContentNegotiationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, NegotiatedContentType(r))
+	}))
+	tests := []struct {
+		accept string
+		status int
+		body   string
+	}{
+		{"", http.StatusOK, "application/json"},
+		{"text/csv, application/json;q=0.9", http.StatusOK, "text/csv"},
+		{"application/cbor;q=0.5, application/msgpack;q=0.5", http.StatusOK, "application/msgpack"},
+		{"image/png", http.StatusNotAcceptable, "Not Acceptable\n"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.status || w.Body.String() != tt.body || w.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: %d %q, Vary %q", tt.accept, w.Code, w.Body, w.Header().Get("Vary"))
+		}
+	}
+
+	if got := NegotiatedContentType(httptest.NewRequest("GET", "/", nil)); got != "" {
+		t.Errorf("NegotiatedContentType outside the middleware = %q", got)
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{"Vary": {"Accept-Encoding, origin"}}
+	addVary(h, "Origin")
+	addVary(h, "Accept")
+	addVary(h, "accept")
+	if got := h.Values("Vary"); !reflect.DeepEqual(got, []string{"Accept-Encoding, origin", "Accept"}) {
+		t.Errorf("Vary = %q", got)
+	}
+}
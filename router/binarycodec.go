@@ -0,0 +1,537 @@
+package router
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// binaryWriter is implemented by the MessagePack and CBOR writers. Values are
+// walked once by encodeBinary and emitted through these primitives.
+type binaryWriter interface {
+	writeNil()
+	writeBool(b bool)
+	writeInt(i int64)
+	writeUint(u uint64)
+	writeFloat32(f float32)
+	writeFloat64(f float64)
+	writeString(s string)
+	writeBytes(b []byte)
+	writeTime(t time.Time)
+	writeArrayHeader(n int)
+	writeMapHeader(n int)
+}
+
+// maxBinaryDepth bounds nesting when encoding and decoding binary formats.
+const maxBinaryDepth = 1000
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// encodeBinary walks v the way encoding/json would, honoring json struct
+// tags and omitempty, and writes it to bw.
+func encodeBinary(bw binaryWriter, v reflect.Value, depth int) error {
+	if depth > maxBinaryDepth {
+		return errors.New("value nested too deeply")
+	}
+	if !v.IsValid() {
+		bw.writeNil()
+		return nil
+	}
+
+	if v.Type() == timeType {
+		bw.writeTime(v.Interface().(time.Time))
+		return nil
+	}
+	if v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface && v.CanInterface() {
+		if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+			text, err := tm.MarshalText()
+			if err != nil {
+				return err
+			}
+			bw.writeString(string(text))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			bw.writeNil()
+			return nil
+		}
+		return encodeBinary(bw, v.Elem(), depth+1)
+	case reflect.Bool:
+		bw.writeBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bw.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bw.writeUint(v.Uint())
+	case reflect.Float32:
+		bw.writeFloat32(float32(v.Float()))
+	case reflect.Float64:
+		bw.writeFloat64(v.Float())
+	case reflect.String:
+		bw.writeString(v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			bw.writeNil()
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(b), v)
+			bw.writeBytes(b)
+			return nil
+		}
+		bw.writeArrayHeader(v.Len())
+		for i := 0; i < v.Len(); i++ {
+			if err := encodeBinary(bw, v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			bw.writeNil()
+			return nil
+		}
+		bw.writeMapHeader(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := encodeBinary(bw, iter.Key(), depth+1); err != nil {
+				return err
+			}
+			if err := encodeBinary(bw, iter.Value(), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		type present struct {
+			name  string
+			value reflect.Value
+		}
+		var fields []present
+		for _, f := range structFields(v.Type(), "json") {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			fields = append(fields, present{f.name, fv})
+		}
+		bw.writeMapHeader(len(fields))
+		for _, f := range fields {
+			bw.writeString(f.name)
+			if err := encodeBinary(bw, f.value, depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// isEmptyValue matches the omitempty rules of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Pointer:
+		return v.IsNil()
+	}
+	return false
+}
+
+// decodedMap is a decoded map with its entries in wire order. Keys may be of
+// any decoded type.
+type decodedMap []decodedEntry
+
+type decodedEntry struct {
+	key   interface{}
+	value interface{}
+}
+
+// assignDecoded stores a value produced by a binary decoder into dst. Decoded
+// values are nil, bool, int64, uint64, float64, string, []byte, time.Time,
+// []interface{} or decodedMap. path names the location for error messages.
+func assignDecoded(dst reflect.Value, src interface{}, path string) error {
+	if src == nil {
+		switch dst.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+			dst.Set(reflect.Zero(dst.Type()))
+		}
+		return nil
+	}
+
+	if dst.Kind() == reflect.Pointer {
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return assignDecoded(dst.Elem(), src, path)
+	}
+
+	if dst.Type() == timeType {
+		switch t := src.(type) {
+		case time.Time:
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		case string:
+			parsed, err := time.Parse(time.RFC3339Nano, t)
+			if err != nil {
+				return fmt.Errorf("%s: %v", pathOrRoot(path), err)
+			}
+			dst.Set(reflect.ValueOf(parsed))
+			return nil
+		}
+		return decodeTypeError(src, dst.Type(), path)
+	}
+	if s, ok := src.(string); ok && dst.CanAddr() && dst.Addr().Type().Implements(textUnmarshalerType) {
+		if err := dst.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("%s: %v", pathOrRoot(path), err)
+		}
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		dst.Set(reflect.ValueOf(genericDecoded(src)))
+		return nil
+	case reflect.Bool:
+		b, ok := src.(bool)
+		if !ok {
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		switch n := src.(type) {
+		case int64:
+			i = n
+		case uint64:
+			if n > math.MaxInt64 {
+				return decodeOverflowError(src, dst.Type(), path)
+			}
+			i = int64(n)
+		case float64:
+			if n != math.Trunc(n) || n < math.MinInt64 || n >= math.MaxInt64 {
+				return decodeTypeError(src, dst.Type(), path)
+			}
+			i = int64(n)
+		default:
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		if dst.OverflowInt(i) {
+			return decodeOverflowError(src, dst.Type(), path)
+		}
+		dst.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		var u uint64
+		switch n := src.(type) {
+		case uint64:
+			u = n
+		case int64:
+			if n < 0 {
+				return decodeOverflowError(src, dst.Type(), path)
+			}
+			u = uint64(n)
+		case float64:
+			if n != math.Trunc(n) || n < 0 || n >= math.MaxUint64 {
+				return decodeTypeError(src, dst.Type(), path)
+			}
+			u = uint64(n)
+		default:
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		if dst.OverflowUint(u) {
+			return decodeOverflowError(src, dst.Type(), path)
+		}
+		dst.SetUint(u)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		switch n := src.(type) {
+		case float64:
+			dst.SetFloat(n)
+		case int64:
+			dst.SetFloat(float64(n))
+		case uint64:
+			dst.SetFloat(float64(n))
+		default:
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		return nil
+	case reflect.String:
+		switch s := src.(type) {
+		case string:
+			dst.SetString(s)
+		case []byte:
+			dst.SetString(string(s))
+		default:
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			switch b := src.(type) {
+			case []byte:
+				dst.SetBytes(append([]byte(nil), b...))
+				return nil
+			case string:
+				dst.SetBytes([]byte(b))
+				return nil
+			}
+		}
+		items, ok := src.([]interface{})
+		if !ok {
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		slice := reflect.MakeSlice(dst.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := assignDecoded(slice.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		dst.Set(slice)
+		return nil
+	case reflect.Array:
+		if b, ok := src.([]byte); ok && dst.Type().Elem().Kind() == reflect.Uint8 {
+			reflect.Copy(dst, reflect.ValueOf(b))
+			return nil
+		}
+		items, ok := src.([]interface{})
+		if !ok {
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		for i := 0; i < dst.Len(); i++ {
+			if i >= len(items) {
+				dst.Index(i).Set(reflect.Zero(dst.Type().Elem()))
+				continue
+			}
+			if err := assignDecoded(dst.Index(i), items[i], fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		entries, ok := src.(decodedMap)
+		if !ok {
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMapWithSize(dst.Type(), len(entries)))
+		}
+		for _, entry := range entries {
+			key := reflect.New(dst.Type().Key()).Elem()
+			if err := assignDecoded(key, entry.key, path); err != nil {
+				return err
+			}
+			if key.Kind() == reflect.Interface && !key.IsNil() && !key.Elem().Type().Comparable() {
+				return fmt.Errorf("%s: %s cannot be a map key", pathOrRoot(path), decodedKind(entry.key))
+			}
+			value := reflect.New(dst.Type().Elem()).Elem()
+			if err := assignDecoded(value, entry.value, fmt.Sprintf("%s.%v", path, entry.key)); err != nil {
+				return err
+			}
+			dst.SetMapIndex(key, value)
+		}
+		return nil
+	case reflect.Struct:
+		entries, ok := src.(decodedMap)
+		if !ok {
+			return decodeTypeError(src, dst.Type(), path)
+		}
+		fields := structFields(dst.Type(), "json")
+		for _, entry := range entries {
+			name, ok := entry.key.(string)
+			if !ok {
+				continue
+			}
+			f, ok := matchField(fields, name)
+			if !ok {
+				continue
+			}
+			fv, err := settableFieldByIndex(dst, f.index)
+			if err != nil {
+				return fmt.Errorf("%s: %v", joinPath(path, f.name), err)
+			}
+			if err := assignDecoded(fv, entry.value, joinPath(path, f.name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return decodeTypeError(src, dst.Type(), path)
+}
+
+// matchField finds the field for a decoded key, preferring an exact match
+// and falling back to a case-insensitive one like encoding/json.
+func matchField(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
+
+// settableFieldByIndex returns the field at index, allocating nil embedded
+// pointers on the way. Like encoding/json, it fails on a nil pointer to an
+// unexported embedded struct, which cannot be set.
+func settableFieldByIndex(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// genericDecoded converts a decoded value for storage in an interface{},
+// turning maps with string keys into map[string]interface{}.
+func genericDecoded(src interface{}) interface{} {
+	switch v := src.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = genericDecoded(item)
+		}
+		return out
+	case decodedMap:
+		stringKeys := true
+		for _, entry := range v {
+			if _, ok := entry.key.(string); !ok {
+				stringKeys = false
+				break
+			}
+		}
+		if stringKeys {
+			out := make(map[string]interface{}, len(v))
+			for _, entry := range v {
+				out[entry.key.(string)] = genericDecoded(entry.value)
+			}
+			return out
+		}
+		out := make(map[interface{}]interface{}, len(v))
+		for _, entry := range v {
+			key := entry.key
+			if b, ok := key.([]byte); ok {
+				key = string(b)
+			}
+			// Nil keys are kept; arrays and maps cannot be map keys in Go.
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				key = fmt.Sprint(genericDecoded(key))
+			}
+			out[key] = genericDecoded(entry.value)
+		}
+		return out
+	}
+	return src
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "value"
+	}
+	return path
+}
+
+func decodeTypeError(src interface{}, t reflect.Type, path string) error {
+	return fmt.Errorf("%s: cannot decode %s into %s", pathOrRoot(path), decodedKind(src), t)
+}
+
+func decodeOverflowError(src interface{}, t reflect.Type, path string) error {
+	return fmt.Errorf("%s: %v overflows %s", pathOrRoot(path), src, t)
+}
+
+func decodedKind(src interface{}) string {
+	switch src.(type) {
+	case bool:
+		return "boolean"
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	case []byte:
+		return "bytes"
+	case time.Time:
+		return "timestamp"
+	case []interface{}:
+		return "array"
+	case decodedMap:
+		return "map"
+	}
+	return fmt.Sprintf("%T", src)
+}
+
+// binaryReader is a bounds-checked cursor over an encoded document.
+type binaryReader struct {
+	data []byte
+	pos  int
+}
+
+var errBinaryTruncated = errors.New("unexpected end of data")
+
+func (br *binaryReader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(br.data)-br.pos {
+		return nil, errBinaryTruncated
+	}
+	b := br.data[br.pos : br.pos+n]
+	br.pos += n
+	return b, nil
+}
+
+func (br *binaryReader) byte() (byte, error) {
+	b, err := br.next(1)
+	if err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+// checkCount rejects element counts that cannot possibly fit in the rest of
+// the input, so hostile headers cannot force huge allocations.
+func (br *binaryReader) checkCount(n uint64, minSize int) (int, error) {
+	if n > uint64(len(br.data)-br.pos)/uint64(minSize) {
+		return 0, errBinaryTruncated
+	}
+	return int(n), nil
+}
@@ -0,0 +1,208 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type codecItem struct {
+	Name  string            `json:"name"`
+	Qty   int               `json:"qty"`
+	Price float64           `json:"price"`
+	Tags  []string          `json:"tags,omitempty"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+	When  time.Time         `json:"when"`
+	Raw   []byte            `json:"raw,omitempty"`
+	Next  *codecItem        `json:"next,omitempty"`
+}
+
+var binaryCodecs = []struct {
+	name      string
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte, interface{}) error
+}{
+	{"msgpack", MarshalMsgPack, UnmarshalMsgPack},
+	{"cbor", MarshalCBOR, UnmarshalCBOR},
+}
+
+func TestBinaryCodecRoundTrip(t *testing.T) {
+	when := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	values := []interface{}{
+		codecItem{Name: "widget", Qty: 3, Price: 9.5, When: when},
+		codecItem{
+			Name:  "nested",
+			Qty:   -70000,
+			Tags:  []string{"a", "b"},
+			Attrs: map[string]string{"color": "red"},
+			When:  when,
+			Raw:   []byte{0, 1, 2},
+			Next:  &codecItem{Name: "child", Qty: 1 << 40, When: when},
+		},
+	}
+
+	for _, codec := range binaryCodecs {
+		for _, want := range values {
+			data, err := codec.marshal(want)
+			if err != nil {
+				t.Fatalf("%s: marshal: %v", codec.name, err)
+			}
+			var got codecItem
+			if err := codec.unmarshal(data, &got); err != nil {
+				t.Fatalf("%s: unmarshal: %v", codec.name, err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: round trip = %+v, want %+v", codec.name, got, want)
+			}
+		}
+	}
+}
+
+func TestBinaryCodecDecodeErrors(t *testing.T) {
+	tests := []struct {
+		codec string
+		data  []byte
+		into  interface{}
+	}{
+		{"msgpack", []byte{0xc1}, new(interface{})},
+		{"msgpack", []byte{0x92, 0x01}, new(interface{})},
+		{"msgpack", []byte{0x01, 0x02}, new(interface{})},
+		{"msgpack", []byte{0xcd, 0x01, 0x00}, new(int8)},
+		{"msgpack", []byte{0xa1, 'x'}, new(int)},
+		{"cbor", []byte{0xff, 0xff}, new(interface{})},
+		{"cbor", []byte{0x82, 0x01}, new(interface{})},
+		{"cbor", []byte{0x19, 0x01, 0x00}, new(uint8)},
+		{"cbor", []byte{0x61, 'x'}, new(bool)},
+	}
+
+	for _, tt := range tests {
+		unmarshal := UnmarshalMsgPack
+		if tt.codec == "cbor" {
+			unmarshal = UnmarshalCBOR
+		}
+		if err := unmarshal(tt.data, tt.into); err == nil {
+			t.Errorf("%s: decoding % x into %T succeeded, want an error", tt.codec, tt.data, tt.into)
+		}
+	}
+}
+
+func TestBinaryCodecNilMapKey(t *testing.T) {
+	tests := []struct {
+		codec string
+		data  []byte
+	}{
+		// {nil: 1, 1: 2}
+		{"msgpack", []byte{0x82, 0xc0, 0x01, 0x01, 0x02}},
+		{"cbor", []byte{0xa2, 0xf6, 0x01, 0x01, 0x02}},
+	}
+
+	for _, tt := range tests {
+		unmarshal := UnmarshalMsgPack
+		if tt.codec == "cbor" {
+			unmarshal = UnmarshalCBOR
+		}
+
+		var generic interface{}
+		if err := unmarshal(tt.data, &generic); err != nil {
+			t.Fatalf("%s: unmarshal into interface{}: %v", tt.codec, err)
+		}
+		m, ok := generic.(map[interface{}]interface{})
+		if !ok {
+			t.Fatalf("%s: decoded %T, want map[interface{}]interface{}", tt.codec, generic)
+		}
+		if _, ok := m[nil]; !ok || len(m) != 2 {
+			t.Errorf("%s: decoded %v, want a nil key and one other", tt.codec, m)
+		}
+
+		var keyed map[interface{}]int
+		if err := unmarshal(tt.data, &keyed); err != nil {
+			t.Fatalf("%s: unmarshal into map[interface{}]int: %v", tt.codec, err)
+		}
+		if len(keyed) != 2 {
+			t.Errorf("%s: decoded %v, want two entries", tt.codec, keyed)
+		}
+	}
+}
+
+func TestBinaryCodecUnhashableMapKey(t *testing.T) {
+	// {[1]: 2}, an array used as a map key.
+	for _, tt := range []struct {
+		codec string
+		data  []byte
+	}{
+		{"msgpack", []byte{0x81, 0x91, 0x01, 0x02}},
+		{"cbor", []byte{0xa1, 0x81, 0x01, 0x02}},
+	} {
+		unmarshal := UnmarshalMsgPack
+		if tt.codec == "cbor" {
+			unmarshal = UnmarshalCBOR
+		}
+
+		var generic interface{}
+		if err := unmarshal(tt.data, &generic); err != nil {
+			t.Errorf("%s: unmarshal into interface{}: %v", tt.codec, err)
+		}
+		var keyed map[interface{}]int
+		if err := unmarshal(tt.data, &keyed); err == nil {
+			t.Errorf("%s: unmarshal into map[interface{}]int succeeded, want an error", tt.codec)
+		}
+	}
+}
+
+type codecInner struct {
+	A int `json:"a"`
+}
+
+type codecOuter struct {
+	*codecInner
+	B int `json:"b"`
+}
+
+func TestBinaryCodecUnexportedEmbeddedPointer(t *testing.T) {
+	for _, codec := range binaryCodecs {
+		data, err := codec.marshal(map[string]int{"a": 1, "b": 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got codecOuter
+		if err := codec.unmarshal(data, &got); err == nil {
+			t.Errorf("%s: decoding into a nil unexported embedded pointer succeeded: %+v", codec.name, got)
+		}
+
+		got = codecOuter{codecInner: &codecInner{}}
+		if err := codec.unmarshal(data, &got); err != nil || got.A != 1 || got.B != 2 {
+			t.Errorf("%s: decoding into an allocated embedded pointer = %+v, %v", codec.name, got, err)
+		}
+	}
+}
+
+func TestBindBinaryBodyTooLarge(t *testing.T) {
+	tests := []struct {
+		contentType string
+		bind        func(*http.Request, interface{}) error
+	}{
+		{"application/msgpack", BindMsgPack},
+		{"application/cbor", BindCBOR},
+	}
+
+	for _, tt := range tests {
+		body := bytes.Repeat([]byte{0}, maxBinaryBodySize+1)
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		r.Header.Set("Content-Type", tt.contentType)
+
+		var v interface{}
+		err := tt.bind(r, &v)
+		var tooLarge *RequestTooLargeError
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("%s: error = %v, want *RequestTooLargeError", tt.contentType, err)
+		}
+		if tooLarge.StatusCode() != 413 {
+			t.Errorf("%s: status = %d, want 413", tt.contentType, tooLarge.StatusCode())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package router
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Binder decodes a request body into v.
+type Binder interface {
+	Bind(r *http.Request, v interface{}) error
+}
+
+// BinderFunc adapts an ordinary function to the Binder interface.
+type BinderFunc func(r *http.Request, v interface{}) error
+
+// Bind calls f(r, v).
+func (f BinderFunc) Bind(r *http.Request, v interface{}) error {
+	return f(r, v)
+}
+
+var (
+	bindersMu sync.RWMutex
+	binders   = map[string]Binder{}
+)
+
+func init() {
+	RegisterBinder("application/json", BinderFunc(BindJSON))
+	RegisterBinder("application/xml", BinderFunc(BindXML))
+	RegisterBinder("text/xml", BinderFunc(BindXML))
+	RegisterBinder("application/x-www-form-urlencoded", BinderFunc(BindForm))
+	RegisterBinder("multipart/form-data", BinderFunc(func(r *http.Request, v interface{}) error {
+		return BindMultipart(r, v, MultipartOptions{})
+	}))
+	RegisterBinder("application/msgpack", BinderFunc(BindMsgPack))
+	RegisterBinder("application/x-msgpack", BinderFunc(BindMsgPack))
+	RegisterBinder("application/cbor", BinderFunc(BindCBOR))
+	RegisterBinder("+json", BinderFunc(decodeJSON))
+	RegisterBinder("+xml", BinderFunc(decodeXML))
+}
+
+// RegisterBinder makes b the binder Bind uses for mediaType, replacing any
+// previous one. A media type starting with "+" is a structured syntax
+// suffix: "+json" is used for types such as application/vnd.api+json that
+// have no binder of their own.
+func RegisterBinder(mediaType string, b Binder) {
+	bindersMu.Lock()
+	defer bindersMu.Unlock()
+	binders[strings.ToLower(mediaType)] = b
+}
+
+func binderFor(mediaType string) (Binder, bool) {
+	bindersMu.RLock()
+	defer bindersMu.RUnlock()
+	if b, ok := binders[mediaType]; ok {
+		return b, true
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		b, ok := binders[mediaType[i:]]
+		return b, ok
+	}
+	return nil, false
+}
+
+// binderMediaTypes lists the media types with a registered binder.
+func binderMediaTypes() []string {
+	bindersMu.RLock()
+	defer bindersMu.RUnlock()
+	types := make([]string, 0, len(binders))
+	for mediaType := range binders {
+		if !strings.HasPrefix(mediaType, "+") {
+			types = append(types, mediaType)
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// UnsupportedMediaTypeError is returned by Bind when no binder handles the
+// request's Content-Type.
+type UnsupportedMediaTypeError struct {
+	ContentType string
+	// Supported lists the media types that can be bound.
+	Supported []string
+}
+
+func (e *UnsupportedMediaTypeError) Error() string {
+	if e.ContentType == "" {
+		return "unsupported media type: missing Content-Type"
+	}
+	return fmt.Sprintf("unsupported media type %q", e.ContentType)
+}
+
+// StatusCode returns 415 Unsupported Media Type.
+func (e *UnsupportedMediaTypeError) StatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
+// BindOption customizes a single call to Bind.
+type BindOption func(*bindOptions)
+
+type bindOptions struct {
+	validate bool
+}
+
+// WithValidation makes Bind run ValidateStruct on v once it is bound.
+// Failures are returned as a *ValidationError.
+func WithValidation() BindOption {
+	return func(o *bindOptions) {
+		o.validate = true
+	}
+}
+
+// Bind decodes the request body into v with the binder registered for the
+// request's Content-Type. Requests without a body bind nothing. When no
+// binder matches, an *UnsupportedMediaTypeError is returned.
+func Bind(r *http.Request, v interface{}, opts ...BindOption) error {
+	var o bindOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := bindBody(r, v); err != nil {
+		return err
+	}
+	if o.validate {
+		return validationResult(r, v)
+	}
+	return nil
+}
+
+func bindBody(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &UnsupportedMediaTypeError{ContentType: contentType, Supported: binderMediaTypes()}
+	}
+	b, ok := binderFor(mediaType)
+	if !ok {
+		return &UnsupportedMediaTypeError{ContentType: mediaType, Supported: binderMediaTypes()}
+	}
+	return b.Bind(r, v)
+}
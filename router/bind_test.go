@@ -0,0 +1,126 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type bindItem struct {
+	Name string `json:"name" xml:"name" form:"name" validate:"required"`
+	Qty  int    `json:"qty" xml:"qty" form:"qty"`
+}
+
+func bindRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+func TestBind(t *testing.T) {
+	want := bindItem{Name: "pen", Qty: 2}
+	cbor, _ := MarshalCBOR(want)
+	msgpack, _ := MarshalMsgPack(want)
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", `{"name":"pen","qty":2}`},
+		{"Application/JSON; charset=utf-8", `{"name":"pen","qty":2}`},
+		{"application/vnd.api+json", `{"name":"pen","qty":2}`},
+		{"application/xml", `<item><name>pen</name><qty>2</qty></item>`},
+		{"text/xml; charset=utf-8", `<item><name>pen</name><qty>2</qty></item>`},
+		{"application/atom+xml", `<item><name>pen</name><qty>2</qty></item>`},
+		{"application/x-www-form-urlencoded", "name=pen&qty=2"},
+		{"application/cbor", string(cbor)},
+		{"application/x-msgpack", string(msgpack)},
+	}
+
+	for _, tt := range tests {
+		var got bindItem
+		if err := Bind(bindRequest(tt.contentType, tt.body), &got); err != nil {
+			t.Errorf("%s: %v", tt.contentType, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: bound %+v", tt.contentType, got)
+		}
+	}
+}
+
+func TestBindUnsupportedMediaType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        string
+	}{
+		{"", ""},
+		{"text/csv", "text/csv"},
+		{"application/json;;", "application/json;;"},
+	}
+
+	for _, tt := range tests {
+		err := Bind(bindRequest(tt.contentType, "x"), &bindItem{})
+		var unsupported *UnsupportedMediaTypeError
+		if !errors.As(err, &unsupported) || unsupported.ContentType != tt.want || unsupported.StatusCode() != http.StatusUnsupportedMediaType {
+			t.Errorf("%q: error = %v", tt.contentType, err)
+			continue
+		}
+		for _, mediaType := range unsupported.Supported {
+			if strings.HasPrefix(mediaType, "+") {
+				t.Errorf("%q: Supported lists the suffix %s", tt.contentType, mediaType)
+			}
+		}
+		if len(unsupported.Supported) == 0 {
+			t.Errorf("%q: Supported is empty", tt.contentType)
+		}
+	}
+
+	// A request without a body binds nothing, whatever its Content-Type.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Content-Type", "text/csv")
+	if err := Bind(r, &bindItem{}); err != nil {
+		t.Errorf("bodiless request: %v", err)
+	}
+}
+
+func TestRegisterBinder(t *testing.T) {
+	RegisterBinder("Text/Vnd.Router-Test", BinderFunc(func(r *http.Request, v interface{}) error {
+		name, qty, _ := strings.Cut(r.FormValue("x"), ":")
+		*v.(*bindItem) = bindItem{Name: name, Qty: len(qty)}
+		return nil
+	}))
+	var got bindItem
+	r := bindRequest("text/vnd.router-test", "")
+	r.URL.RawQuery = "x=pen:ab"
+	if err := Bind(r, &got); err != nil || got != (bindItem{"pen", 2}) {
+		t.Errorf("bound %+v, %v", got, err)
+	}
+	found := false
+	for _, mediaType := range binderMediaTypes() {
+		found = found || mediaType == "text/vnd.router-test"
+	}
+	if types := binderMediaTypes(); !found || !sort.StringsAreSorted(types) {
+		t.Errorf("binderMediaTypes() = %v", types)
+	}
+}
+
+func TestBindWithValidation(t *testing.T) {
+	var got bindItem
+	err := Bind(bindRequest("application/json", `{"qty":1}`), &got, WithValidation())
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Errors) != 1 || invalid.Errors[0].Field != "name" {
+		t.Fatalf("error = %v, want a *ValidationError for name", err)
+	}
+	if err := Bind(bindRequest("application/json", `{"name":"pen"}`), &got, WithValidation()); err != nil {
+		t.Errorf("valid body: %v", err)
+	}
+	// Decoding errors come before validation.
+	if err := Bind(bindRequest("application/json", `{`), &got, WithValidation()); errors.As(err, &invalid) {
+		t.Errorf("malformed body: error = %v, want a decoding error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCacheMiss is returned by CacheStore.Get when no entry exists for a key.
+var ErrCacheMiss = errors.New("cache: miss")
+
+// CacheEntry is a captured response held by a CacheStore.
+type CacheEntry struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Expires ends the period in which the entry is served as fresh.
+	Expires time.Time
+	// RevalidateUntil and ErrorUntil end the stale-while-revalidate and
+	// stale-if-error windows that follow Expires.
+	RevalidateUntil time.Time
+	ErrorUntil      time.Time
+	Tags            []string
+	// Vary lists the request headers that select this response, taken from
+	// its Vary header.
+	Vary []string
+}
+
+// CacheStore persists the responses of a Cache. Implementations must be safe
+// for concurrent use.
+type CacheStore interface {
+	// Get returns the entry for key or ErrCacheMiss.
+	Get(key string) (*CacheEntry, error)
+	// Set stores entry under key, replacing any previous entry.
+	Set(key string, entry *CacheEntry) error
+	// Delete removes the entry for key if there is one.
+	Delete(key string) error
+	// DeleteFunc removes every entry for which match returns true. The
+	// entry passed to match need not carry its Body.
+	DeleteFunc(match func(key string, entry *CacheEntry) bool) error
+}
+
+// retainUntil is the point after which an entry can no longer be served in
+// any mode and may be dropped by a store.
+func (entry *CacheEntry) retainUntil() time.Time {
+	until := entry.Expires
+	if entry.RevalidateUntil.After(until) {
+		until = entry.RevalidateUntil
+	}
+	if entry.ErrorUntil.After(until) {
+		until = entry.ErrorUntil
+	}
+	return until
+}
+
+// MemoryStore is a CacheStore that keeps entries in process memory.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	items map[string]*CacheEntry
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[string]*CacheEntry)}
+}
+
+func (s *MemoryStore) Get(key string) (*CacheEntry, error) {
+	s.mu.RLock()
+	entry, exists := s.items[key]
+	s.mu.RUnlock()
+	if !exists {
+		return nil, ErrCacheMiss
+	}
+	if time.Now().After(entry.retainUntil()) {
+		s.Delete(key)
+		return nil, ErrCacheMiss
+	}
+	return entry, nil
+}
+
+func (s *MemoryStore) Set(key string, entry *CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+	return nil
+}
+
+func (s *MemoryStore) DeleteFunc(match func(key string, entry *CacheEntry) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, entry := range s.items {
+		if match(key, entry) {
+			delete(s.items, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// testCacheStore checks the CacheStore contract against store.
+func testCacheStore(t *testing.T, store CacheStore) {
+	t.Helper()
+	entry := func(body string, expires time.Duration, tags ...string) *CacheEntry {
+		return &CacheEntry{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       []byte(body),
+			Expires:    time.Now().Add(expires),
+			Tags:       tags,
+			Vary:       []string{"Accept"},
+		}
+	}
+
+	if _, err := store.Get("/a"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Get on an empty store: error = %v, want ErrCacheMiss", err)
+	}
+	for key, e := range map[string]*CacheEntry{
+		"/a":       entry("a", time.Minute, "x"),
+		"/b":       entry("b", time.Minute),
+		"/c":       entry("c", time.Minute, "x", "y"),
+		"/expired": entry("old", -time.Second),
+	} {
+		if err := store.Set(key, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Set("/b", entry("b2", time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Get("/b")
+	if err != nil || string(got.Body) != "b2" || got.StatusCode != http.StatusOK || got.Header.Get("Content-Type") != "text/plain" || len(got.Vary) != 1 {
+		t.Errorf("Get(/b) = %+v, %v", got, err)
+	}
+	if _, err := store.Get("/expired"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Get(/expired) error = %v, want ErrCacheMiss", err)
+	}
+
+	err = store.DeleteFunc(func(key string, e *CacheEntry) bool {
+		return len(e.Tags) > 0 && e.Tags[0] == "x"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("/b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("/missing"); err != nil {
+		t.Errorf("Delete(/missing): %v", err)
+	}
+	for _, key := range []string{"/a", "/b", "/c"} {
+		if _, err := store.Get(key); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("Get(%s) after deletion: error = %v", key, err)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testCacheStore(t, NewMemoryStore())
+}
+
+func TestCacheEntryRetainUntil(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		entry CacheEntry
+		want  time.Time
+	}{
+		{CacheEntry{Expires: now}, now},
+		{CacheEntry{Expires: now, RevalidateUntil: now.Add(time.Minute)}, now.Add(time.Minute)},
+		{CacheEntry{Expires: now, RevalidateUntil: now.Add(time.Minute), ErrorUntil: now.Add(time.Hour)}, now.Add(time.Hour)},
+		{CacheEntry{Expires: now, ErrorUntil: now.Add(-time.Hour)}, now},
+	}
+
+	for i, tt := range tests {
+		if got := tt.entry.retainUntil(); !got.Equal(tt.want) {
+			t.Errorf("%d: retainUntil() = %v, want %v", i, got, tt.want)
+		}
+	}
+}
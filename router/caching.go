@@ -2,82 +2,488 @@ package router
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"log"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
-type cacheItem struct {
-	body       []byte
-	header     http.Header
-	expiration time.Time
+// CacheOptions configures a Cache created with NewCache.
+type CacheOptions struct {
+	// Duration is how long a successful response is served from the cache.
+	Duration time.Duration
+	// Store holds the cached responses. Defaults to a new MemoryStore.
+	Store CacheStore
+	// Coalesce makes concurrent misses for the same key wait on a single
+	// upstream execution and share its captured response.
+	Coalesce bool
+	// CoalesceTimeout bounds how long a waiter blocks on the in-flight
+	// request before calling the handler itself. Zero waits for the leader.
+	CoalesceTimeout time.Duration
+	// StaleWhileRevalidate is how long an expired response may still be
+	// served while it is refreshed in the background (RFC 5861).
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is how long an expired response may be served in place
+	// of a 5xx from the handler (RFC 5861).
+	StaleIfError time.Duration
+	// InvalidateOnWrite evicts the cached GET responses for a path, under
+	// any query string, after a successful POST, PUT, PATCH or DELETE to
+	// that path.
+	InvalidateOnWrite bool
 }
 
-var (
-	cache = make(map[string]*cacheItem)
-	lock  = sync.RWMutex{}
-)
+// CacheTagHeader is the response header handlers can use to tag a cached
+// response for InvalidateTag. Tags are separated by commas or spaces and the
+// header is not sent to clients.
+const CacheTagHeader = "Cache-Tag"
+
+// Cache is a response cache for GET requests. Create one with NewCache and
+// register its Middleware method on a router or route.
+type Cache struct {
+	opts   CacheOptions
+	store  CacheStore
+	flight *cacheFlight
+}
+
+// cacheTags collects the tags added with AddCacheTags during a request.
+type cacheTags struct {
+	mu   sync.Mutex
+	tags []string
+}
+
+// cacheFlight tracks the upstream executions currently filling the cache.
+type cacheFlight struct {
+	mu    sync.Mutex
+	calls map[string]*cacheCall
+}
+
+type cacheCall struct {
+	done    chan struct{}
+	entry   *CacheEntry
+	variant string
+}
+
+// defaultCache backs CachingMiddleware. The router rebuilds its middleware
+// chain on every request, so the state has to outlive each call.
+var defaultCache = NewCache(CacheOptions{})
+
+// NewCache creates a Cache backed by opts.Store.
+func NewCache(opts CacheOptions) *Cache {
+	store := opts.Store
+	if store == nil {
+		store = NewMemoryStore()
+	}
+	return &Cache{
+		opts:   opts,
+		store:  store,
+		flight: &cacheFlight{calls: make(map[string]*cacheCall)},
+	}
+}
+
+// DefaultCache returns the Cache that CachingMiddleware stores responses in,
+// so they can be invalidated by key, prefix or tag.
+func DefaultCache() *Cache {
+	return defaultCache
+}
 
+// CachingMiddleware caches successful GET responses produced by next for
+// duration in DefaultCache.
 func CachingMiddleware(duration time.Duration, next http.Handler) http.Handler {
+	c := *defaultCache
+	c.opts.Duration = duration
+	return c.Middleware(next)
+}
+
+// Middleware caches successful GET responses produced by next.
+func (c *Cache) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" { // Only cache GET requests
-			next.ServeHTTP(w, r)
+			if !c.opts.InvalidateOnWrite || !isUnsafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			sw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(sw, r)
+			if sw.statusCode >= 200 && sw.statusCode < 300 {
+				path, _, _ := strings.Cut(cacheKey(r), "?")
+				c.InvalidatePath(path)
+			}
+			return
+		}
+
+		key := cacheKey(r)
+		stale := c.get(key)
+		if stale != nil && len(stale.Vary) > 0 {
+			// The base entry only records which request headers select
+			// the variant; the response itself is stored under a
+			// variant key.
+			key = variantKey(r, stale.Vary)
+			stale = c.get(key)
+		}
+		if stale != nil {
+			now := time.Now()
+			if now.Before(stale.Expires) {
+				stale.writeTo(w)
+				return
+			}
+			if now.Before(stale.RevalidateUntil) {
+				c.revalidate(key, next, r)
+				stale.writeTo(w)
+				return
+			}
+		}
+
+		if !c.opts.Coalesce {
+			c.fill(next, r).serve(w, stale)
+			return
+		}
+
+		call, leader := c.flight.join(key)
+		if leader {
+			c.lead(key, call, next, r).serve(w, stale)
 			return
 		}
 
-		lock.RLock()
-		item, exists := cache[r.RequestURI]
-		lock.RUnlock()
+		var timeout <-chan time.Time
+		if c.opts.CoalesceTimeout > 0 {
+			timer := time.NewTimer(c.opts.CoalesceTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
 
-		if exists && time.Now().Before(item.expiration) {
-			for k, v := range item.header {
-				w.Header()[k] = v
+		select {
+		case <-call.done:
+			if call.entry != nil && call.entry.sharedWith(r, call.variant) {
+				call.entry.serve(w, stale)
+				return
 			}
-			w.Write(item.body)
+		case <-timeout:
+		case <-r.Context().Done():
 			return
 		}
 
-		cw := &cacheWriter{
-			ResponseWriter: w,
-			statusCode:     http.StatusOK,
-			header:         http.Header{},
+		// The leader failed, took too long or produced a different
+		// variant; serve this request directly.
+		c.fill(next, r).serve(w, stale)
+	})
+}
+
+// get returns the stored entry for key, or nil when there is none. Store
+// failures are logged and treated as a miss.
+func (c *Cache) get(key string) *CacheEntry {
+	entry, err := c.store.Get(key)
+	if err != nil {
+		if !errors.Is(err, ErrCacheMiss) {
+			log.Printf("cache: get %s: %v", key, err)
+		}
+		return nil
+	}
+	return entry
+}
+
+// revalidate refreshes key in the background unless a refresh is already
+// running. The request is detached from the client's cancellation.
+func (c *Cache) revalidate(key string, next http.Handler, r *http.Request) {
+	call, leader := c.flight.join(key)
+	if !leader {
+		return
+	}
+	req := r.Clone(context.WithoutCancel(r.Context()))
+	go c.lead(key, call, next, req)
+}
+
+// lead runs the upstream request on behalf of every waiter on call. Waiters
+// are released even if the handler panics.
+func (c *Cache) lead(key string, call *cacheCall, next http.Handler, r *http.Request) (entry *CacheEntry) {
+	defer func() {
+		call.entry = entry
+		if entry != nil {
+			call.variant = variantKey(r, entry.Vary)
+		}
+		c.flight.finish(key)
+		close(call.done)
+	}()
+	return c.fill(next, r)
+}
+
+// fill executes next with a capturing writer and stores the response if it
+// can be cached. Responses with a Vary header are stored under a variant key,
+// with an entry at the request URI recording the varying headers.
+func (c *Cache) fill(next http.Handler, r *http.Request) *CacheEntry {
+	cw := &cacheWriter{
+		statusCode: http.StatusOK,
+		header:     http.Header{},
+	}
+
+	tags := &cacheTags{}
+	next.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), cacheTagsKey, tags)))
+
+	tags.add(strings.FieldsFunc(cw.header.Get(CacheTagHeader), func(r rune) bool {
+		return r == ',' || r == ' '
+	})...)
+	cw.header.Del(CacheTagHeader)
+
+	entry := &CacheEntry{
+		StatusCode: cw.statusCode,
+		Header:     cw.header,
+		Body:       cw.body.Bytes(),
+		Expires:    time.Now().Add(c.opts.Duration),
+		Tags:       tags.tags,
+		Vary:       varyFields(cw.header),
+	}
+	directives := parseCacheControl(cw.header.Get("Cache-Control"))
+	entry.RevalidateUntil = entry.Expires.Add(directives.window("stale-while-revalidate", c.opts.StaleWhileRevalidate))
+	entry.ErrorUntil = entry.Expires.Add(directives.window("stale-if-error", c.opts.StaleIfError))
+
+	if entry.StatusCode == http.StatusOK && entry.cacheable() { // Only cache successful responses
+		key := cacheKey(r)
+		if len(entry.Vary) > 0 {
+			marker := &CacheEntry{
+				Expires:         entry.Expires,
+				RevalidateUntil: entry.RevalidateUntil,
+				ErrorUntil:      entry.ErrorUntil,
+				Tags:            entry.Tags,
+				Vary:            entry.Vary,
+			}
+			c.set(key, marker)
+			key = variantKey(r, entry.Vary)
+		}
+		c.set(key, entry)
+	}
+	return entry
+}
+
+func (c *Cache) set(key string, entry *CacheEntry) {
+	if err := c.store.Set(key, entry); err != nil {
+		log.Printf("cache: set %s: %v", key, err)
+	}
+}
+
+// varyFields returns the canonical request header names listed in the Vary
+// header of h.
+func varyFields(h http.Header) []string {
+	var fields []string
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				fields = append(fields, http.CanonicalHeaderKey(field))
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// cacheKey is the key of the response to r: its request URI as the client
+// sent it, so escaped paths and query strings are kept apart.
+func cacheKey(r *http.Request) string {
+	if r.RequestURI != "" {
+		return r.RequestURI
+	}
+	return r.URL.RequestURI()
+}
+
+// variantKey is the key under which the variant of the response to r
+// selected by the vary fields is stored.
+func variantKey(r *http.Request, vary []string) string {
+	if len(vary) == 0 {
+		return cacheKey(r)
+	}
+	var b strings.Builder
+	b.WriteString(cacheKey(r))
+	for _, field := range vary {
+		value := strings.Join(r.Header.Values(field), ",")
+		value = strings.ToLower(strings.ReplaceAll(value, " ", ""))
+		b.WriteString(variantSeparator + field + "=" + value)
+	}
+	return b.String()
+}
+
+// variantSeparator joins a request URI and the header values selecting a
+// variant. It cannot appear in a request URI.
+const variantSeparator = "\x00"
+
+// cacheable reports whether the response may be stored. Vary: * means the
+// response depends on more than the request headers.
+func (entry *CacheEntry) cacheable() bool {
+	for _, field := range entry.Vary {
+		if field == "*" {
+			return false
 		}
+	}
+	return true
+}
 
-		next.ServeHTTP(cw, r)
+// sharedWith reports whether a response produced for the variant key variant
+// can also answer r.
+func (entry *CacheEntry) sharedWith(r *http.Request, variant string) bool {
+	return entry.cacheable() && variantKey(r, entry.Vary) == variant
+}
+
+// InvalidateKey evicts the response cached for a request URI, including all
+// of its variants.
+func (c *Cache) InvalidateKey(key string) {
+	c.deleteFunc(func(k string, _ *CacheEntry) bool {
+		return k == key || strings.HasPrefix(k, key+variantSeparator)
+	})
+}
 
-		if cw.statusCode == http.StatusOK { // Only cache successful responses
-			lock.Lock()
-			cache[r.RequestURI] = &cacheItem{
-				body:       cw.body.Bytes(),
-				header:     cw.header,
-				expiration: time.Now().Add(duration),
+// InvalidatePrefix evicts every response whose request URI starts with prefix.
+func (c *Cache) InvalidatePrefix(prefix string) {
+	c.deleteFunc(func(key string, _ *CacheEntry) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+// InvalidatePath evicts the responses cached for path under any query string.
+// Keys hold paths as clients send them, so path must be escaped the same
+// way, as by url.URL.EscapedPath.
+func (c *Cache) InvalidatePath(path string) {
+	c.deleteFunc(func(key string, _ *CacheEntry) bool {
+		return key == path || strings.HasPrefix(key, path+"?") || strings.HasPrefix(key, path+variantSeparator)
+	})
+}
+
+// InvalidateTag evicts every response tagged with any of tags.
+func (c *Cache) InvalidateTag(tags ...string) {
+	c.deleteFunc(func(_ string, entry *CacheEntry) bool {
+		for _, tag := range entry.Tags {
+			for _, t := range tags {
+				if tag == t {
+					return true
+				}
 			}
-			lock.Unlock()
 		}
+		return false
 	})
 }
 
+func (c *Cache) deleteFunc(match func(key string, entry *CacheEntry) bool) {
+	if err := c.store.DeleteFunc(match); err != nil {
+		log.Printf("cache: invalidate: %v", err)
+	}
+}
+
+// AddCacheTags tags the response being cached for r so it can later be
+// evicted with InvalidateTag. It has no effect outside a cached GET request.
+func AddCacheTags(r *http.Request, tags ...string) {
+	if ct, ok := r.Context().Value(cacheTagsKey).(*cacheTags); ok {
+		ct.add(tags...)
+	}
+}
+
+func (ct *cacheTags) add(tags ...string) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+	ct.tags = append(ct.tags, tags...)
+}
+
+func isUnsafeMethod(method string) bool {
+	switch method {
+	case "POST", "PUT", "PATCH", "DELETE":
+		return true
+	}
+	return false
+}
+
+// serve writes entry, or stale in its place when entry is a server error and
+// stale is still inside its stale-if-error window.
+func (entry *CacheEntry) serve(w http.ResponseWriter, stale *CacheEntry) {
+	if entry.StatusCode >= 500 && stale != nil && time.Now().Before(stale.ErrorUntil) {
+		stale.writeTo(w)
+		return
+	}
+	entry.writeTo(w)
+}
+
+func (entry *CacheEntry) writeTo(w http.ResponseWriter) {
+	for k, v := range entry.Header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(entry.StatusCode)
+	w.Write(entry.Body)
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(header string) cacheControl {
+	directives := cacheControl{}
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return directives
+}
+
+// window returns the number of seconds set by directive, or def when the
+// directive is absent or malformed.
+func (cc cacheControl) window(directive string, def time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(cc[directive])
+	if err != nil || seconds < 0 {
+		return def
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// join registers interest in key and reports whether the caller is the
+// leader responsible for executing the request.
+func (f *cacheFlight) join(key string) (*cacheCall, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if call, exists := f.calls[key]; exists {
+		return call, false
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	f.calls[key] = call
+	return call, true
+}
+
+func (f *cacheFlight) finish(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.calls, key)
+}
+
+// cacheWriter buffers a response so it can be stored and replayed.
 type cacheWriter struct {
-	http.ResponseWriter
-	body       *bytes.Buffer
-	statusCode int
-	header     http.Header
+	body        bytes.Buffer
+	statusCode  int
+	header      http.Header
+	wroteHeader bool
 }
 
 func (cw *cacheWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
 	cw.statusCode = statusCode
-	cw.ResponseWriter.WriteHeader(statusCode)
 }
 
 func (cw *cacheWriter) Write(b []byte) (int, error) {
-	if cw.body == nil {
-		cw.body = bytes.NewBuffer(b)
-	} else {
-		cw.body.Write(b)
-	}
-	return cw.ResponseWriter.Write(b)
+	cw.wroteHeader = true
+	return cw.body.Write(b)
 }
 
 func (cw *cacheWriter) Header() http.Header {
 	return cw.header
 }
+
+// statusWriter records the status code written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sw *statusWriter) WriteHeader(statusCode int) {
+	sw.statusCode = statusCode
+	sw.ResponseWriter.WriteHeader(statusCode)
+}
@@ -0,0 +1,362 @@
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler answers with its call count, so cached responses show an
+// older count.
+type countingHandler struct {
+	calls  int
+	status int
+	header http.Header
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.calls++
+	for k, v := range h.header {
+		w.Header()[k] = v
+	}
+	if h.status != 0 {
+		w.WriteHeader(h.status)
+	}
+	fmt.Fprintf(w, "%d", h.calls)
+}
+
+func cacheRequest(t *testing.T, h http.Handler, method, target string, header ...string) string {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	body, _ := io.ReadAll(w.Body)
+	return string(body)
+}
+
+func TestCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		header   http.Header
+		requests [][]string // method, target and header pairs
+		want     []string
+	}{
+		{"hit", 0, nil, [][]string{{"GET", "/a"}, {"GET", "/a"}}, []string{"1", "1"}},
+		{"query strings differ", 0, nil, [][]string{{"GET", "/a?x=1"}, {"GET", "/a?x=2"}, {"GET", "/a?x=1"}}, []string{"1", "2", "1"}},
+		{"errors are not cached", 500, nil, [][]string{{"GET", "/a"}, {"GET", "/a"}}, []string{"1", "2"}},
+		{"other methods pass through", 0, nil, [][]string{{"POST", "/a"}, {"POST", "/a"}}, []string{"1", "2"}},
+		{
+			"vary",
+			0, http.Header{"Vary": {"Accept-Language"}},
+			[][]string{{"GET", "/a", "Accept-Language", "en"}, {"GET", "/a", "Accept-Language", "fr"}, {"GET", "/a", "Accept-Language", "en"}},
+			[]string{"1", "2", "1"},
+		},
+		{"vary star", 0, http.Header{"Vary": {"*"}}, [][]string{{"GET", "/a"}, {"GET", "/a"}}, []string{"1", "2"}},
+	}
+
+	for _, tt := range tests {
+		next := &countingHandler{status: tt.status, header: tt.header}
+		h := NewCache(CacheOptions{Duration: time.Minute}).Middleware(next)
+		for i, req := range tt.requests {
+			if got := cacheRequest(t, h, req[0], req[1], req[2:]...); got != tt.want[i] {
+				t.Errorf("%s: request %d got %q, want %q", tt.name, i, got, tt.want[i])
+			}
+		}
+	}
+}
+
+func TestCacheInvalidateOnWrite(t *testing.T) {
+	next := &countingHandler{}
+	h := NewCache(CacheOptions{Duration: time.Minute, InvalidateOnWrite: true}).Middleware(next)
+
+	targets := []string{"/files/a%20b", "/files/a%20b?page=2", "/files/a%2Fb", "/files/other"}
+	for _, target := range targets {
+		cacheRequest(t, h, "GET", target)
+	}
+	calls := next.calls
+
+	cacheRequest(t, h, "PUT", "/files/a%20b?version=3")
+	calls++
+	for _, target := range targets[:2] {
+		if got := cacheRequest(t, h, "GET", target); got == "1" || got == "2" {
+			t.Errorf("GET %s after PUT served the cached %q", target, got)
+		}
+		calls++
+	}
+	for _, target := range targets[2:] {
+		cacheRequest(t, h, "GET", target)
+	}
+	if next.calls != calls {
+		t.Errorf("unrelated paths were evicted: %d calls, want %d", next.calls, calls)
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(c *Cache)
+		evicted    []string
+	}{
+		{"key", func(c *Cache) { c.InvalidateKey("/users?page=1") }, []string{"/users?page=1"}},
+		{"path", func(c *Cache) { c.InvalidatePath("/users") }, []string{"/users", "/users?page=1"}},
+		{"prefix", func(c *Cache) { c.InvalidatePrefix("/users") }, []string{"/users", "/users?page=1", "/users/7"}},
+		{"tag", func(c *Cache) { c.InvalidateTag("user-7") }, []string{"/users/7"}},
+	}
+	targets := []string{"/users", "/users?page=1", "/users/7", "/orders"}
+
+	for _, tt := range tests {
+		c := NewCache(CacheOptions{Duration: time.Minute})
+		calls := map[string]int{}
+		h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls[r.RequestURI]++
+			if strings.HasPrefix(r.URL.Path, "/users/") {
+				w.Header().Set(CacheTagHeader, "user-7 users")
+			}
+			io.WriteString(w, "ok")
+		}))
+		for _, target := range targets {
+			cacheRequest(t, h, "GET", target)
+		}
+		tt.invalidate(c)
+		for _, target := range targets {
+			cacheRequest(t, h, "GET", target)
+		}
+
+		evicted := map[string]bool{}
+		for _, target := range tt.evicted {
+			evicted[target] = true
+		}
+		for _, target := range targets {
+			if want := map[bool]int{true: 2, false: 1}[evicted[target]]; calls[target] != want {
+				t.Errorf("%s: %s was served %d times by the handler, want %d", tt.name, target, calls[target], want)
+			}
+		}
+	}
+}
+
+func TestCachingMiddlewareDefaultCache(t *testing.T) {
+	next := &countingHandler{header: http.Header{CacheTagHeader: {"default-cache-test"}}}
+	h := CachingMiddleware(time.Minute, next)
+	// DefaultCache is shared, so the test keeps to paths of its own and
+	// leaves none of them cached.
+	t.Cleanup(func() { DefaultCache().InvalidatePrefix("/default-cache/") })
+	tests := []struct {
+		target     string
+		invalidate func(c *Cache)
+	}{
+		{"/default-cache/key", func(c *Cache) { c.InvalidateKey("/default-cache/key") }},
+		{"/default-cache/prefix/a", func(c *Cache) { c.InvalidatePrefix("/default-cache/prefix/") }},
+		{"/default-cache/tag", func(c *Cache) { c.InvalidateTag("default-cache-test") }},
+	}
+
+	for _, tt := range tests {
+		first := cacheRequest(t, h, "GET", tt.target)
+		if got := cacheRequest(t, h, "GET", tt.target); got != first {
+			t.Errorf("%s: second GET = %q, want the cached %q", tt.target, got, first)
+		}
+		tt.invalidate(DefaultCache())
+		if got := cacheRequest(t, h, "GET", tt.target); got == first {
+			t.Errorf("%s: GET after invalidation served the cached %q", tt.target, got)
+		}
+	}
+}
+
+func TestCacheTagHeaderHidden(t *testing.T) {
+	h := NewCache(CacheOptions{Duration: time.Minute}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(CacheTagHeader, "a")
+		io.WriteString(w, "ok")
+	}))
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Header().Get(CacheTagHeader) != "" {
+			t.Errorf("response %d sent the %s header", i, CacheTagHeader)
+		}
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	h := NewCache(CacheOptions{Duration: time.Minute, Coalesce: true}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		<-release
+		fmt.Fprintf(w, "%d", n)
+	}))
+
+	const clients = 20
+	bodies := make(chan string, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bodies <- cacheRequest(t, h, "GET", "/slow")
+		}()
+	}
+	// Give every client time to join the leader before it answers.
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(bodies)
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("handler ran %d times for %d concurrent misses", n, clients)
+	}
+	for body := range bodies {
+		if body != "1" {
+			t.Errorf("client got %q, want the leader's response", body)
+		}
+	}
+}
+
+func TestCacheCoalesceTimeout(t *testing.T) {
+	var calls atomic.Int64
+	started, release := make(chan struct{}), make(chan struct{})
+	h := NewCache(CacheOptions{Duration: time.Minute, Coalesce: true, CoalesceTimeout: 10 * time.Millisecond}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == 1 {
+			close(started)
+			<-release
+		}
+		fmt.Fprintf(w, "%d", n)
+	}))
+
+	leader := make(chan string)
+	go func() { leader <- cacheRequest(t, h, "GET", "/slow") }()
+	<-started
+	if got := cacheRequest(t, h, "GET", "/slow"); got != "2" {
+		t.Errorf("waiter past the timeout got %q, want its own response", got)
+	}
+	close(release)
+	if got := <-leader; got != "1" {
+		t.Errorf("leader got %q", got)
+	}
+}
+
+// staleEntry is a response that expired a second ago.
+func staleEntry(revalidate, onError time.Duration) *CacheEntry {
+	expired := time.Now().Add(-time.Second)
+	return &CacheEntry{
+		StatusCode:      http.StatusOK,
+		Header:          http.Header{},
+		Body:            []byte("stale"),
+		Expires:         expired,
+		RevalidateUntil: expired.Add(revalidate),
+		ErrorUntil:      expired.Add(onError),
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	store := NewMemoryStore()
+	store.Set("/a", staleEntry(time.Minute, 0))
+	refreshed := make(chan struct{})
+	h := NewCache(CacheOptions{Duration: time.Minute, Store: store}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(refreshed)
+		io.WriteString(w, "fresh")
+	}))
+
+	if got := cacheRequest(t, h, "GET", "/a"); got != "stale" {
+		t.Errorf("inside the window got %q, want the stale response", got)
+	}
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("the stale response was not revalidated")
+	}
+	// The refresh stores its response just after the handler returns.
+	deadline := time.Now().Add(time.Second)
+	for cacheRequest(t, h, "GET", "/a") != "fresh" {
+		if time.Now().After(deadline) {
+			t.Fatal("the refreshed response was never served")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCacheStaleIfError(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry *CacheEntry
+		want  string
+	}{
+		{"inside the window", staleEntry(0, time.Minute), "stale"},
+		{"past the window", staleEntry(0, 0), "down"},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		store.Set("/a", tt.entry)
+		h := NewCache(CacheOptions{Duration: time.Minute, Store: store}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}))
+		if got := strings.TrimSpace(cacheRequest(t, h, "GET", "/a")); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCacheStaleDirectives(t *testing.T) {
+	tests := []struct {
+		cacheControl        string
+		revalidate, onError time.Duration
+	}{
+		{"", 5 * time.Second, 10 * time.Second},
+		{"max-age=60, stale-while-revalidate=30", 30 * time.Second, 10 * time.Second},
+		{`Stale-If-Error="600"`, 5 * time.Second, 600 * time.Second},
+		{"stale-while-revalidate=0, stale-if-error=-1", 0, 10 * time.Second},
+		{"stale-while-revalidate=soon", 5 * time.Second, 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		store := NewMemoryStore()
+		opts := CacheOptions{Duration: time.Minute, Store: store, StaleWhileRevalidate: 5 * time.Second, StaleIfError: 10 * time.Second}
+		h := NewCache(opts).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", tt.cacheControl)
+		}))
+		cacheRequest(t, h, "GET", "/a")
+
+		entry, err := store.Get("/a")
+		if err != nil {
+			t.Fatalf("%q: %v", tt.cacheControl, err)
+		}
+		if got := entry.RevalidateUntil.Sub(entry.Expires); got != tt.revalidate {
+			t.Errorf("%q: stale-while-revalidate window %v, want %v", tt.cacheControl, got, tt.revalidate)
+		}
+		if got := entry.ErrorUntil.Sub(entry.Expires); got != tt.onError {
+			t.Errorf("%q: stale-if-error window %v, want %v", tt.cacheControl, got, tt.onError)
+		}
+	}
+}
+
+func TestAddCacheTags(t *testing.T) {
+	c := NewCache(CacheOptions{Duration: time.Minute})
+	next := &countingHandler{}
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddCacheTags(r, "user-"+r.URL.Query().Get("id"))
+		next.ServeHTTP(w, r)
+	}))
+
+	cacheRequest(t, h, "GET", "/profile?id=1")
+	cacheRequest(t, h, "GET", "/profile?id=2")
+	c.InvalidateTag("user-2", "user-3")
+	if got := cacheRequest(t, h, "GET", "/profile?id=1"); got != "1" {
+		t.Errorf("untagged response was evicted, got %q", got)
+	}
+	if got := cacheRequest(t, h, "GET", "/profile?id=2"); got != "3" {
+		t.Errorf("tagged response was not evicted, got %q", got)
+	}
+
+	// Outside a cached request the call does nothing.
+	AddCacheTags(httptest.NewRequest("GET", "/", nil), "x")
+}
@@ -0,0 +1,415 @@
+package router
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+	"unicode/utf8"
+)
+
+// CBOREncoder renders values as CBOR (RFC 8949), registered for
+// application/cbor.
+var CBOREncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+	data, err := MarshalCBOR(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+})
+
+// CBOR major types.
+const (
+	cborUint byte = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// MarshalCBOR encodes v as CBOR using definite lengths. Structs become maps
+// keyed by their json tag names, and time.Time is written as an RFC 3339
+// string under tag 0.
+func MarshalCBOR(v interface{}) ([]byte, error) {
+	var cw cborWriter
+	if err := encodeBinary(&cw, reflect.ValueOf(v), 0); err != nil {
+		return nil, fmt.Errorf("cbor: %v", err)
+	}
+	return cw.buf.Bytes(), nil
+}
+
+// UnmarshalCBOR decodes a CBOR document into v, which must be a non-nil
+// pointer. Map keys are matched to struct fields by json tag name. Epoch
+// (tag 1) and RFC 3339 (tag 0) times decode into time.Time; other tags are
+// ignored and their content decoded as usual.
+func UnmarshalCBOR(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("cbor: Unmarshal(non-pointer %T)", v)
+	}
+
+	br := &binaryReader{data: data}
+	decoded, err := readCBOR(br, 0)
+	if err != nil {
+		return fmt.Errorf("cbor: %v at offset %d", err, br.pos)
+	}
+	if br.pos != len(data) {
+		return fmt.Errorf("cbor: unexpected data after top-level value at offset %d", br.pos)
+	}
+	if err := assignDecoded(rv.Elem(), decoded, ""); err != nil {
+		return fmt.Errorf("cbor: %v", err)
+	}
+	return nil
+}
+
+type cborWriter struct {
+	buf bytes.Buffer
+}
+
+// writeHead writes a major type with its argument in the shortest form.
+func (cw *cborWriter) writeHead(major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		cw.buf.WriteByte(major | byte(arg))
+	case arg <= math.MaxUint8:
+		cw.buf.Write([]byte{major | 24, byte(arg)})
+	case arg <= math.MaxUint16:
+		cw.buf.WriteByte(major | 25)
+		cw.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(arg)))
+	case arg <= math.MaxUint32:
+		cw.buf.WriteByte(major | 26)
+		cw.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(arg)))
+	default:
+		cw.buf.WriteByte(major | 27)
+		cw.buf.Write(binary.BigEndian.AppendUint64(nil, arg))
+	}
+}
+
+func (cw *cborWriter) writeNil() {
+	cw.buf.WriteByte(cborSimple | 22)
+}
+
+func (cw *cborWriter) writeBool(b bool) {
+	if b {
+		cw.buf.WriteByte(cborSimple | 21)
+	} else {
+		cw.buf.WriteByte(cborSimple | 20)
+	}
+}
+
+func (cw *cborWriter) writeInt(i int64) {
+	if i >= 0 {
+		cw.writeHead(cborUint, uint64(i))
+		return
+	}
+	cw.writeHead(cborNegInt, uint64(-1-i))
+}
+
+func (cw *cborWriter) writeUint(u uint64) {
+	cw.writeHead(cborUint, u)
+}
+
+func (cw *cborWriter) writeFloat32(f float32) {
+	cw.buf.WriteByte(cborSimple | 26)
+	cw.buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(f)))
+}
+
+func (cw *cborWriter) writeFloat64(f float64) {
+	cw.buf.WriteByte(cborSimple | 27)
+	cw.buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+func (cw *cborWriter) writeString(s string) {
+	cw.writeHead(cborText, uint64(len(s)))
+	cw.buf.WriteString(s)
+}
+
+func (cw *cborWriter) writeBytes(b []byte) {
+	cw.writeHead(cborBytes, uint64(len(b)))
+	cw.buf.Write(b)
+}
+
+func (cw *cborWriter) writeTime(t time.Time) {
+	cw.writeHead(cborTag, 0)
+	cw.writeString(t.Format(time.RFC3339Nano))
+}
+
+func (cw *cborWriter) writeArrayHeader(n int) {
+	cw.writeHead(cborArray, uint64(n))
+}
+
+func (cw *cborWriter) writeMapHeader(n int) {
+	cw.writeHead(cborMap, uint64(n))
+}
+
+// cborBreak is returned by readCBOR for the stop code ending an
+// indefinite-length item.
+type cborBreak struct{}
+
+func readCBOR(br *binaryReader, depth int) (interface{}, error) {
+	item, err := readCBORItem(br, depth)
+	if _, ok := item.(cborBreak); ok && err == nil {
+		return nil, fmt.Errorf("unexpected break code")
+	}
+	return item, err
+}
+
+func readCBORItem(br *binaryReader, depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, fmt.Errorf("document nested too deeply")
+	}
+	initial, err := br.byte()
+	if err != nil {
+		return nil, err
+	}
+	major, info := initial&0xe0, initial&0x1f
+
+	if info == 31 {
+		return readCBORIndefinite(br, major, depth)
+	}
+	if major == cborSimple {
+		return readCBORSimple(br, info)
+	}
+
+	arg, err := readCBORArgument(br, info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		return arg, nil
+	case cborNegInt:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("negative integer overflows int64")
+		}
+		return -1 - int64(arg), nil
+	case cborBytes, cborText:
+		size, err := br.checkCount(arg, 1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := br.next(size)
+		if err != nil {
+			return nil, err
+		}
+		if major == cborBytes {
+			return append([]byte(nil), b...), nil
+		}
+		if !utf8.Valid(b) {
+			return nil, fmt.Errorf("invalid UTF-8 in text string")
+		}
+		return string(b), nil
+	case cborArray:
+		count, err := br.checkCount(arg, 1)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = readCBOR(br, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	case cborMap:
+		count, err := br.checkCount(arg, 2)
+		if err != nil {
+			return nil, err
+		}
+		entries := make(decodedMap, count)
+		for i := range entries {
+			if entries[i].key, err = readCBOR(br, depth+1); err != nil {
+				return nil, err
+			}
+			if entries[i].value, err = readCBOR(br, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return entries, nil
+	case cborTag:
+		content, err := readCBOR(br, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return cborTagged(arg, content)
+	}
+	return nil, fmt.Errorf("invalid major type %d", major>>5)
+}
+
+func readCBORArgument(br *binaryReader, info byte) (uint64, error) {
+	if info < 24 {
+		return uint64(info), nil
+	}
+	if info > 27 {
+		return 0, fmt.Errorf("invalid additional information %d", info)
+	}
+	b, err := br.next(1 << (info - 24))
+	if err != nil {
+		return 0, err
+	}
+	var arg uint64
+	for _, c := range b {
+		arg = arg<<8 | uint64(c)
+	}
+	return arg, nil
+}
+
+func readCBORSimple(br *binaryReader, info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: // null, undefined
+		return nil, nil
+	case 25:
+		b, err := br.next(2)
+		if err != nil {
+			return nil, err
+		}
+		return halfToFloat64(binary.BigEndian.Uint16(b)), nil
+	case 26:
+		b, err := br.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 27:
+		b, err := br.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case 31:
+		return cborBreak{}, nil
+	}
+	if info == 24 {
+		if _, err := br.next(1); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("unsupported simple value")
+}
+
+// readCBORIndefinite reads the chunks or items of an indefinite-length
+// string, array or map up to its break code.
+func readCBORIndefinite(br *binaryReader, major byte, depth int) (interface{}, error) {
+	switch major {
+	case cborBytes, cborText:
+		var buf []byte
+		for {
+			chunk, err := readCBORItem(br, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			switch c := chunk.(type) {
+			case cborBreak:
+				if major == cborText {
+					return string(buf), nil
+				}
+				return buf, nil
+			case []byte:
+				if major != cborBytes {
+					return nil, fmt.Errorf("invalid chunk in indefinite text string")
+				}
+				buf = append(buf, c...)
+			case string:
+				if major != cborText {
+					return nil, fmt.Errorf("invalid chunk in indefinite byte string")
+				}
+				buf = append(buf, c...)
+			default:
+				return nil, fmt.Errorf("invalid chunk in indefinite string")
+			}
+		}
+	case cborArray:
+		var items []interface{}
+		for {
+			item, err := readCBORItem(br, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := item.(cborBreak); ok {
+				if items == nil {
+					items = []interface{}{}
+				}
+				return items, nil
+			}
+			items = append(items, item)
+		}
+	case cborMap:
+		entries := decodedMap{}
+		for {
+			key, err := readCBORItem(br, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := key.(cborBreak); ok {
+				return entries, nil
+			}
+			value, err := readCBOR(br, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, decodedEntry{key: key, value: value})
+		}
+	case cborSimple:
+		return cborBreak{}, nil
+	}
+	return nil, fmt.Errorf("invalid indefinite length for major type %d", major>>5)
+}
+
+func cborTagged(tag uint64, content interface{}) (interface{}, error) {
+	switch tag {
+	case 0:
+		s, ok := content.(string)
+		if !ok {
+			return nil, fmt.Errorf("tag 0 requires a text string")
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	case 1:
+		switch n := content.(type) {
+		case uint64:
+			return time.Unix(int64(n), 0).UTC(), nil
+		case int64:
+			return time.Unix(n, 0).UTC(), nil
+		case float64:
+			sec, frac := math.Modf(n)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+		}
+		return nil, fmt.Errorf("tag 1 requires a number")
+	}
+	return content, nil
+}
+
+// halfToFloat64 converts an IEEE 754 half-precision value.
+func halfToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
@@ -0,0 +1,120 @@
+package router
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Encodings from RFC 8949, Appendix A.
+func TestMarshalCBOR(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{1000, "1903e8"},
+		{uint64(1000000), "1a000f4240"},
+		{uint64(1000000000000), "1b000000e8d4a51000"},
+		{-1, "20"},
+		{-1000, "3903e7"},
+		{1.1, "fb3ff199999999999a"},
+		{float32(100000.0), "fa47c35000"},
+		{false, "f4"},
+		{true, "f5"},
+		{nil, "f6"},
+		{"", "60"},
+		{"a", "6161"},
+		{"ü", "62c3bc"},
+		{[]byte{1, 2, 3, 4}, "4401020304"},
+		{[]int{}, "80"},
+		{[]int{1, 2, 3}, "83010203"},
+		{[]interface{}{1, []int{2, 3}}, "8201820203"},
+		{map[string]int{"a": 1}, "a1616101"},
+		{struct {
+			A int    `json:"a"`
+			B string `json:"b,omitempty"`
+		}{A: 1}, "a1616101"},
+	}
+
+	for _, tt := range tests {
+		data, err := MarshalCBOR(tt.v)
+		if err != nil {
+			t.Errorf("MarshalCBOR(%#v): %v", tt.v, err)
+			continue
+		}
+		if got := hex.EncodeToString(data); got != tt.want {
+			t.Errorf("MarshalCBOR(%#v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalCBOR(t *testing.T) {
+	tests := []struct {
+		data string
+		want interface{}
+	}{
+		{"1b000000e8d4a51000", int64(1000000000000)},
+		{"3903e7", -1000},
+		{"f93c00", 1.0},
+		{"f97bff", 65504.0},
+		{"f9c400", -4.0},
+		{"f90001", 5.960464477539063e-8},
+		{"f97c00", math.Inf(1)},
+		{"fa47c35000", 100000.0},
+		{"f6", (*int)(nil)},
+		{"7f657374726561646d696e67ff", "streaming"},
+		{"5f42010243030405ff", []byte{1, 2, 3, 4, 5}},
+		{"9f018202039f0405ffff", []interface{}{uint64(1), []interface{}{uint64(2), uint64(3)}, []interface{}{uint64(4), uint64(5)}}},
+		{"bf61610161629f0203ffff", map[string]interface{}{"a": uint64(1), "b": []interface{}{uint64(2), uint64(3)}}},
+		{"c074323031332d30332d32315432303a30343a30305a", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+		{"c11a514b67b0", time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := reflect.New(reflect.TypeOf(tt.want))
+		if err := UnmarshalCBOR(data, got.Interface()); err != nil {
+			t.Errorf("UnmarshalCBOR(%s): %v", tt.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Elem().Interface(), tt.want) {
+			t.Errorf("UnmarshalCBOR(%s) = %#v, want %#v", tt.data, got.Elem().Interface(), tt.want)
+		}
+	}
+}
+
+func TestUnmarshalCBORErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"18",                 // truncated argument
+		"1c",                 // reserved additional information
+		"62c3",               // truncated text
+		"62c328",             // invalid UTF-8
+		"ff",                 // break outside an indefinite item
+		"7f4101ff",           // byte chunk in a text string
+		"9f01",               // unterminated array
+		"3bffffffffffffffff", // overflows int64
+		"0000",               // trailing data
+	} {
+		b, _ := hex.DecodeString(data)
+		var v interface{}
+		if err := UnmarshalCBOR(b, &v); err == nil {
+			t.Errorf("UnmarshalCBOR(%s) = %#v, want an error", data, v)
+		}
+	}
+
+	nested := bytes.Repeat([]byte{0x81}, maxBinaryDepth+1)
+	var v interface{}
+	if err := UnmarshalCBOR(append(nested, 0x00), &v); err == nil {
+		t.Error("UnmarshalCBOR accepted a document nested too deeply")
+	}
+}
@@ -0,0 +1,281 @@
+package router
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CompressOptions configures the Compress middleware.
+type CompressOptions struct {
+	// Level is the gzip/zlib compression level, from gzip.HuffmanOnly (-2)
+	// to gzip.BestCompression (9). Zero uses the default level.
+	Level int
+	// MinSize is the smallest response, in bytes, worth compressing.
+	// Defaults to 1024. Streamed responses are compressed from their first
+	// Flush regardless of size.
+	MinSize int
+	// ContentTypes lists the compressible media types. An entry ending in
+	// "/" or "+" matches by prefix or suffix respectively, e.g. "text/" or
+	// "+json". Defaults to common text, JSON, XML, YAML and CSV types.
+	ContentTypes []string
+}
+
+var defaultCompressibleTypes = []string{
+	"text/",
+	"application/json",
+	"application/xml",
+	"application/javascript",
+	"application/yaml",
+	"application/x-ndjson",
+	"image/svg+xml",
+	"+json",
+	"+xml",
+}
+
+// compressEncodings are the supported codings in order of preference.
+var compressEncodings = []string{"gzip", "deflate"}
+
+// Compress returns middleware that compresses responses with gzip or deflate
+// according to the request's Accept-Encoding header. Only compressible
+// content types at least MinSize long are compressed; Vary: Accept-Encoding
+// is always set so caches such as CachingMiddleware keep each encoding as a
+// separate variant.
+func Compress(opts CompressOptions) Middleware {
+	if opts.MinSize <= 0 {
+		opts.MinSize = 1024
+	}
+	if len(opts.ContentTypes) == 0 {
+		opts.ContentTypes = defaultCompressibleTypes
+	}
+	if opts.Level == 0 {
+		opts.Level = gzip.DefaultCompression
+	}
+	if opts.Level < gzip.HuffmanOnly || opts.Level > gzip.BestCompression {
+		panic("router: Compress level must be between -2 and 9, got " + strconv.Itoa(opts.Level))
+	}
+	pools := map[string]*sync.Pool{
+		"gzip": {New: func() interface{} {
+			zw, _ := gzip.NewWriterLevel(io.Discard, opts.Level)
+			return zw
+		}},
+		"deflate": {New: func() interface{} {
+			zw, _ := zlib.NewWriterLevel(io.Discard, opts.Level)
+			return zw
+		}},
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			addVary(w.Header(), "Accept-Encoding")
+
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), compressEncodings)
+			if encoding == "" || r.Method == "HEAD" || r.Header.Get("Range") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				opts:           &opts,
+				encoding:       encoding,
+				pool:           pools[encoding],
+				statusCode:     http.StatusOK,
+			}
+			defer cw.close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the offered coding with the highest quality in
+// an Accept-Encoding header, or "" when none is acceptable.
+func negotiateEncoding(header string, offers []string) string {
+	specs := parseAcceptHeader(header)
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		q, matched := 0.0, false
+		for _, spec := range specs {
+			if spec.value == offer {
+				q, matched = spec.q, true
+				break
+			}
+		}
+		if !matched {
+			for _, spec := range specs {
+				if spec.value == "*" {
+					q = spec.q
+				}
+			}
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// compressor is implemented by *gzip.Writer and *zlib.Writer.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// compressWriter buffers the start of a response until it can decide whether
+// compressing it is worthwhile.
+type compressWriter struct {
+	http.ResponseWriter
+	opts       *CompressOptions
+	encoding   string
+	pool       *sync.Pool
+	statusCode int
+	buf        []byte
+	decided    bool
+	zw         compressor
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.decided || statusCode < 200 {
+		if !cw.decided {
+			cw.ResponseWriter.WriteHeader(statusCode) // informational
+		}
+		return
+	}
+	cw.statusCode = statusCode
+	if !bodyAllowed(statusCode) {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) < cw.opts.MinSize {
+			return len(b), nil
+		}
+		if err := cw.start(cw.compressible()); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.zw != nil {
+		return cw.zw.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far. A response that is flushed
+// before reaching MinSize is treated as a stream and compressed if its type
+// allows it.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		cw.start(cw.compressible())
+	}
+	if cw.zw != nil {
+		cw.zw.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack lets protocol upgrades such as WebSockets bypass compression.
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		cw.decided = true
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// start decides whether to compress, sends the header and writes any
+// buffered bytes.
+func (cw *compressWriter) start(compress bool) error {
+	cw.decide(compress)
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	buf := cw.buf
+	cw.buf = nil
+	var err error
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+func (cw *compressWriter) decide(compress bool) {
+	cw.decided = true
+	h := cw.Header()
+	if compress {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", cw.encoding)
+		cw.zw = cw.pool.Get().(compressor)
+		cw.zw.Reset(cw.ResponseWriter)
+	}
+	cw.ResponseWriter.WriteHeader(cw.statusCode)
+}
+
+// compressible reports whether the response's type and headers allow
+// compression, sniffing the type from the buffered bytes when it is unset.
+func (cw *compressWriter) compressible() bool {
+	h := cw.Header()
+	if !bodyAllowed(cw.statusCode) || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(h.Get("Cache-Control")), "no-transform") {
+		return false
+	}
+
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf)
+		h.Set("Content-Type", contentType)
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+	for _, t := range cw.opts.ContentTypes {
+		switch {
+		case strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t):
+			return true
+		case strings.HasPrefix(t, "+") && strings.HasSuffix(mediaType, t):
+			return true
+		case mediaType == t:
+			return true
+		}
+	}
+	return false
+}
+
+// close finishes the response once the handler has returned.
+func (cw *compressWriter) close() {
+	if !cw.decided {
+		cw.start(len(cw.buf) >= cw.opts.MinSize && cw.compressible())
+	}
+	if cw.zw != nil {
+		cw.zw.Close()
+		cw.zw.Reset(io.Discard)
+		cw.pool.Put(cw.zw)
+		cw.zw = nil
+	}
+}
+
+func bodyAllowed(statusCode int) bool {
+	return statusCode >= 200 && statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
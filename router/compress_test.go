@@ -0,0 +1,138 @@
+package router
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	long := strings.Repeat("hello compression ", 100)
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		method         string
+		contentType    string
+		cacheControl   string
+		status         int
+		body           string
+		wantEncoding   string
+	}{
+		{"gzip", "gzip", "GET", "text/plain", "", 200, long, "gzip"},
+		{"deflate", "deflate", "GET", "application/json", "", 200, long, "deflate"},
+		{"preference", "deflate;q=0.5, gzip;q=0.8", "GET", "text/plain", "", 200, long, "gzip"},
+		{"wildcard", "*", "GET", "text/plain", "", 200, long, "gzip"},
+		{"suffix type", "gzip", "GET", "application/problem+json", "", 200, long, "gzip"},
+		{"sniffed type", "gzip", "GET", "", "", 200, long, "gzip"},
+		{"not accepted", "br", "GET", "text/plain", "", 200, long, ""},
+		{"refused", "gzip;q=0", "GET", "text/plain", "", 200, long, ""},
+		{"too small", "gzip", "GET", "text/plain", "", 200, "short", ""},
+		{"incompressible", "gzip", "GET", "image/png", "", 200, long, ""},
+		{"no-transform", "gzip", "GET", "text/plain", "no-transform", 200, long, ""},
+		{"head", "gzip", "HEAD", "text/plain", "", 200, "", ""},
+		{"no content", "gzip", "GET", "text/plain", "", 204, "", ""},
+	}
+
+	for _, tt := range tests {
+		h := Compress(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.contentType != "" {
+				w.Header().Set("Content-Type", tt.contentType)
+			}
+			if tt.cacheControl != "" {
+				w.Header().Set("Cache-Control", tt.cacheControl)
+			}
+			w.WriteHeader(tt.status)
+			io.WriteString(w, tt.body)
+		}))
+		r := httptest.NewRequest(tt.method, "/", nil)
+		r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("%s: Content-Encoding = %q, want %q", tt.name, got, tt.wantEncoding)
+			continue
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q", tt.name, w.Header().Get("Vary"))
+		}
+		if w.Code != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if got := decodedBody(t, tt.wantEncoding, w.Body.Bytes()); got != tt.body {
+			t.Errorf("%s: body = %q, want %q", tt.name, got, tt.body)
+		}
+	}
+}
+
+func TestCompressFlush(t *testing.T) {
+	h := Compress(CompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		io.WriteString(w, "{}\n")
+		w.(http.Flusher).Flush()
+		io.WriteString(w, "{}\n")
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("a flushed stream was not compressed")
+	}
+	if got := decodedBody(t, "gzip", w.Body.Bytes()); got != "{}\n{}\n" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestCompressLevel(t *testing.T) {
+	for _, level := range []int{gzip.HuffmanOnly, gzip.BestSpeed, gzip.BestCompression} {
+		h := Compress(CompressOptions{Level: level, MinSize: 1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, "hello")
+		}))
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Encoding", "deflate")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if got := decodedBody(t, "deflate", w.Body.Bytes()); got != "hello" {
+			t.Errorf("level %d: body = %q", level, got)
+		}
+	}
+
+	for _, level := range []int{-3, 10, 12} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("level %d: Compress did not panic", level)
+				}
+			}()
+			Compress(CompressOptions{Level: level})
+		}()
+	}
+}
+
+func decodedBody(t *testing.T, encoding string, body []byte) string {
+	t.Helper()
+	var r io.Reader = bytes.NewReader(body)
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(r)
+	case "deflate":
+		r, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		t.Fatalf("%s: %v", encoding, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", encoding, err)
+	}
+	return string(data)
+}
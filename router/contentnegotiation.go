@@ -1,48 +1,96 @@
 package router
 
 import (
-	"encoding/json"
-	"encoding/xml"
+	"log"
 	"net/http"
 	"strings"
 )
 
-// Respond handles content negotiation and responds in the appropriate format
-func Respond(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
-	accept := r.Header.Get("Accept")
+// RespondOption customizes a single call to Respond.
+type RespondOption func(*respondOptions)
 
-	var content string
-	var marshalErr error
+type respondOptions struct {
+	fields []string
+}
+
+// WithFields limits the response to the named fields, matched by json name,
+// of a struct or of each struct or map in a slice; the items of a
+// PageEnvelope are pruned rather than the envelope. It takes effect in every
+// encoding. A nil list keeps all fields, so the Fields of a Query can be
+// passed as they are.
+func WithFields(fields ...string) RespondOption {
+	return func(o *respondOptions) {
+		o.fields = fields
+	}
+}
 
-	if strings.Contains(accept, "application/xml") {
-		w.Header().Set("Content-Type", "application/xml")
-		content, marshalErr = xmlMarshalIndent(data)
+// Respond handles content negotiation and responds in the appropriate format.
+// It uses the type chosen by ContentNegotiationMiddleware when present and
+// otherwise negotiates the Accept header itself against the registered
+// encoders, replying 406 Not Acceptable when no encoder is acceptable.
+func Respond(w http.ResponseWriter, r *http.Request, status int, data interface{}, opts ...RespondOption) {
+	var o respondOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.fields != nil {
+		data = pruneFields(data, o.fields)
+	}
+
+	contentType := NegotiatedContentType(r)
+	if contentType == "" {
+		addVary(w.Header(), "Accept")
+		contentType = NegotiateContentType(r, encoderMediaTypes())
+	}
+
+	enc, ok := encoderFor(contentType)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+		return
+	}
+
+	if strings.HasPrefix(contentType, "text/") {
+		w.Header().Set("Content-Type", contentType+"; charset=utf-8")
 	} else {
-		w.Header().Set("Content-Type", "application/json")
-		content, marshalErr = jsonMarshalIndent(data)
+		w.Header().Set("Content-Type", contentType)
 	}
 
-	if marshalErr != nil {
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	ew := &encodeWriter{ResponseWriter: w, status: status}
+	if err := enc.Encode(ew, data); err != nil {
+		if !ew.wroteHeader {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("respond: encoding %s: %v", contentType, err)
 		return
 	}
+	ew.writeHeader()
+}
 
-	w.WriteHeader(status)
-	w.Write([]byte(content))
+// encodeWriter delays the status line until the encoder produces its first
+// bytes, so an encoder that fails up front can still be answered with a 500.
+type encodeWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
 }
 
-func jsonMarshalIndent(data interface{}) (string, error) {
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return "", err
+func (ew *encodeWriter) writeHeader() {
+	if !ew.wroteHeader {
+		ew.wroteHeader = true
+		ew.ResponseWriter.WriteHeader(ew.status)
 	}
-	return string(jsonData), nil
 }
 
-func xmlMarshalIndent(data interface{}) (string, error) {
-	xmlData, err := xml.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return "", err
+func (ew *encodeWriter) Write(b []byte) (int, error) {
+	ew.writeHeader()
+	return ew.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming encoders push partial output to the client.
+func (ew *encodeWriter) Flush() {
+	ew.writeHeader()
+	if f, ok := ew.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
 	}
-	return string(xmlData), nil
 }
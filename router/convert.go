@@ -0,0 +1,102 @@
+package router
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setString parses s into v, which must be settable. Pointers are allocated
+// as needed; encoding.TextUnmarshaler implementations (including time.Time,
+// which takes RFC 3339) are used in preference to the kind of v.
+func setString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setString(v.Elem(), s)
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// setStrings stores values in v: every value when v is a slice (or a
+// pointer to one), otherwise the first.
+func setStrings(v reflect.Value, values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+	if !isSliceType(v.Type()) {
+		return setString(v, values[0])
+	}
+
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	list := reflect.MakeSlice(v.Type(), len(values), len(values))
+	for i, value := range values {
+		if err := setString(list.Index(i), value); err != nil {
+			return err
+		}
+	}
+	v.Set(list)
+	return nil
+}
+
+// parseString converts s to a value of type t with setString.
+func parseString(t reflect.Type, s string) (interface{}, error) {
+	v := reflect.New(t).Elem()
+	if err := setString(v, s); err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseString(t *testing.T) {
+	seven := 7
+	tests := []struct {
+		typ     reflect.Type
+		s       string
+		want    interface{}
+		wantErr bool
+	}{
+		{typ: reflect.TypeOf(""), s: "a b", want: "a b"},
+		{typ: reflect.TypeOf(false), s: "true", want: true},
+		{typ: reflect.TypeOf(false), s: "yes", wantErr: true},
+		{typ: reflect.TypeOf(int8(0)), s: "-128", want: int8(-128)},
+		{typ: reflect.TypeOf(int8(0)), s: "128", wantErr: true},
+		{typ: reflect.TypeOf(uint16(0)), s: "65535", want: uint16(65535)},
+		{typ: reflect.TypeOf(uint(0)), s: "-1", wantErr: true},
+		{typ: reflect.TypeOf(float32(0)), s: "1.5", want: float32(1.5)},
+		{typ: reflect.TypeOf(0.0), s: "1e400", wantErr: true},
+		{typ: reflect.TypeOf(time.Duration(0)), s: "90s", want: 90 * time.Second},
+		{typ: reflect.TypeOf(time.Duration(0)), s: "90", wantErr: true},
+		{typ: reflect.TypeOf(time.Time{}), s: "2024-05-01T12:30:00Z", want: time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)},
+		{typ: reflect.TypeOf(time.Time{}), s: "2024-05-01", wantErr: true},
+		{typ: reflect.TypeOf(net.IP{}), s: "10.0.0.1", want: net.ParseIP("10.0.0.1")},
+		{typ: reflect.TypeOf((*int)(nil)), s: "7", want: &seven},
+		{typ: reflect.TypeOf(struct{}{}), s: "x", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseString(tt.typ, tt.s)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s %q: got %#v, want an error", tt.typ, tt.s, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s %q: got %#v, %v, want %#v", tt.typ, tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestSetStrings(t *testing.T) {
+	var target struct {
+		One   int
+		Many  []int
+		Ptr   *[]string
+		Empty []int
+	}
+	v := reflect.ValueOf(&target).Elem()
+	for _, set := range []struct {
+		field  string
+		values []string
+	}{
+		{"One", []string{"1", "2"}},
+		{"Many", []string{"1", "2"}},
+		{"Ptr", []string{"a", "b"}},
+		{"Empty", nil},
+	} {
+		if err := setStrings(v.FieldByName(set.field), set.values); err != nil {
+			t.Fatalf("%s: %v", set.field, err)
+		}
+	}
+	if target.One != 1 || !reflect.DeepEqual(target.Many, []int{1, 2}) ||
+		target.Ptr == nil || !reflect.DeepEqual(*target.Ptr, []string{"a", "b"}) || target.Empty != nil {
+		t.Errorf("setStrings stored %+v", target)
+	}
+
+	if err := setStrings(v.FieldByName("Many"), []string{"1", "x"}); err == nil {
+		t.Error("setStrings accepted an invalid element")
+	}
+}
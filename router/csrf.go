@@ -1,54 +1,302 @@
 package router
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"errors"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/go-playground/validator/v10" // Importing a third-party validation library
 )
 
-// CSRF Middleware
-func CSRFMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			next.ServeHTTP(w, r)
-			return
-		}
+// Errors passed to a CSRF failure handler.
+var (
+	ErrCSRFTokenMissing = errors.New("csrf: token missing")
+	ErrCSRFTokenInvalid = errors.New("csrf: token invalid")
+)
+
+// CSRFOptions configures CSRF. The zero value gives the defaults used by
+// CSRFMiddleware.
+type CSRFOptions struct {
+	// SafeMethods are not checked. Defaults to GET, HEAD, OPTIONS and TRACE.
+	SafeMethods []string
+	// CookieName, HeaderName and FieldName name the token cookie, the
+	// request header and the form field carrying the token. They default to
+	// "csrf_token", "X-CSRF-Token" and "csrf_token".
+	CookieName string
+	HeaderName string
+	FieldName  string
+	// CookiePath defaults to "/". CookieDomain is empty by default, which
+	// limits the cookie to the current host.
+	CookiePath   string
+	CookieDomain string
+	// CookieMaxAge is the lifetime of the cookie in seconds. Zero makes it a
+	// session cookie.
+	CookieMaxAge int
+	// Secure and HTTPOnly set the cookie attributes of the same name.
+	// HTTPOnly stops scripts from reading the token, so it suits pages that
+	// put the token in forms rather than scripts that copy it to a header.
+	Secure   bool
+	HTTPOnly bool
+	// SameSite defaults to http.SameSiteLaxMode.
+	SameSite http.SameSite
+	// ExemptPaths lists request paths that are not checked, as path.Match
+	// patterns such as "/webhooks/*". An entry ending in "/" matches every
+	// path under it.
+	ExemptPaths []string
+	// Exempt, if set, exempts any request for which it returns true.
+	Exempt func(r *http.Request) bool
+	// Key signs tokens with HMAC-SHA256 when set. Use at least 32 random
+	// bytes, kept secret and shared by every server instance.
+	Key []byte
+	// Session returns the session ID or JWT subject that tokens are bound
+	// to when Key is set. Tokens of other sessions are rejected.
+	Session func(r *http.Request) string
+	// ErrorHandler answers requests that fail the check, with
+	// ErrCSRFTokenMissing or ErrCSRFTokenInvalid. Defaults to 403 Forbidden.
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+}
 
-		cookie, err := r.Cookie("csrf_token")
-		if err != nil || cookie.Value == "" {
+func (o *CSRFOptions) setDefaults() {
+	if len(o.SafeMethods) == 0 {
+		o.SafeMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace}
+	}
+	if o.CookieName == "" {
+		o.CookieName = "csrf_token"
+	}
+	if o.HeaderName == "" {
+		o.HeaderName = "X-CSRF-Token"
+	}
+	if o.FieldName == "" {
+		o.FieldName = "csrf_token"
+	}
+	if o.CookiePath == "" {
+		o.CookiePath = "/"
+	}
+	if o.SameSite == 0 {
+		o.SameSite = http.SameSiteLaxMode
+	}
+	if o.ErrorHandler == nil {
+		o.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
 		}
+	}
+}
 
-		csrfToken := r.Header.Get("X-CSRF-Token")
-		if csrfToken == "" {
-			csrfToken = r.PostFormValue("csrf_token")
-		}
+// CSRF returns middleware that protects against cross-site request forgery
+// with double-submit cookies. Requests that are not safe or exempt must send
+// a token matching the token cookie in the token header or form field.
+// TokenFromRequest gives handlers a token to embed in pages; scripts may
+// also send the cookie's value as it is. Responses to requests without a
+// valid cookie set one, so a page served by a GET can make the requests
+// that follow.
+//
+// With a Key, cookies are HMAC-signed and bound to the session named by the
+// Session option, so a cookie planted by another site or left over from
+// another session is rejected. Tokens are compared in constant time.
+func CSRF(opts CSRFOptions) Middleware {
+	opts.setDefaults()
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := &csrfState{opts: opts}
+			cookie, err := r.Cookie(opts.CookieName)
+			valid := err == nil && cookie.Value != "" && opts.verifyCookie(cookie.Value, opts.session(r))
+			if valid {
+				state.token = cookie.Value
+			} else {
+				state.token = opts.newToken(opts.session(r))
+				setCSRFCookie(w, opts, state.token)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), csrfKey, state))
 
-		if csrfToken != cookie.Value {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
+			if containsMethod(opts.SafeMethods, r.Method) || csrfExempt(opts, r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err != nil || cookie.Value == "" {
+				opts.ErrorHandler(w, r, ErrCSRFTokenMissing)
+				return
+			}
 
-		next.ServeHTTP(w, r)
-	})
+			token := r.Header.Get(opts.HeaderName)
+			if token == "" {
+				token = r.PostFormValue(opts.FieldName)
+			}
+			if token == "" {
+				opts.ErrorHandler(w, r, ErrCSRFTokenMissing)
+				return
+			}
+			if !valid || subtle.ConstantTimeCompare(unmaskCSRFToken(token), []byte(cookie.Value)) != 1 {
+				opts.ErrorHandler(w, r, ErrCSRFTokenInvalid)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRFMiddleware is CSRF with the default options.
+func CSRFMiddleware(next http.Handler) http.Handler {
+	return CSRF(CSRFOptions{})(next)
 }
 
-// Function to generate and set CSRF token cookie
+// SetCSRFToken sets a new token cookie with the default options.
 func SetCSRFToken(w http.ResponseWriter) {
-	token := make([]byte, 32)
-	rand.Read(token)
-	encodedToken := base64.StdEncoding.EncodeToString(token)
+	opts := CSRFOptions{}
+	opts.setDefaults()
+	setCSRFCookie(w, opts, opts.newToken(""))
+}
+
+// csrfState is what CSRF stores in the request context.
+type csrfState struct {
+	opts  CSRFOptions
+	token string
+}
+
+// TokenFromRequest returns a CSRF token for r to embed in a page or form,
+// or "" when r did not pass through CSRF. Each call masks the token with a
+// fresh one-time pad, so pages never repeat it and compression cannot leak
+// it (BREACH).
+func TokenFromRequest(r *http.Request) string {
+	state, ok := r.Context().Value(csrfKey).(*csrfState)
+	if !ok {
+		return ""
+	}
+	return maskCSRFToken(state.token)
+}
 
+// RotateCSRFToken replaces the token cookie with a new token bound to
+// session and returns the new token, masked. Call it when a user logs in or
+// out, with the new session ID or JWT subject; an empty session falls back
+// to the Session option. Later calls to TokenFromRequest for r return the
+// new token.
+func RotateCSRFToken(w http.ResponseWriter, r *http.Request, session string) string {
+	state, ok := r.Context().Value(csrfKey).(*csrfState)
+	if !ok {
+		return ""
+	}
+	if session == "" {
+		session = state.opts.session(r)
+	}
+	state.token = state.opts.newToken(session)
+	setCSRFCookie(w, state.opts, state.token)
+	return maskCSRFToken(state.token)
+}
+
+func (o *CSRFOptions) session(r *http.Request) string {
+	if o.Session == nil {
+		return ""
+	}
+	return o.Session(r)
+}
+
+// newToken returns a random token which, with a Key, carries the signature
+// of the token and session: random.signature, both base64url-encoded.
+func (o *CSRFOptions) newToken(session string) string {
+	random := make([]byte, 32)
+	rand.Read(random)
+	if len(o.Key) == 0 {
+		return base64.StdEncoding.EncodeToString(random)
+	}
+	value := base64.RawURLEncoding.EncodeToString(random)
+	return value + "." + base64.RawURLEncoding.EncodeToString(o.sign(value, session))
+}
+
+// verifyCookie reports whether a cookie value is a token signed for session.
+// Without a Key every value is accepted.
+func (o *CSRFOptions) verifyCookie(token, session string) bool {
+	if len(o.Key) == 0 {
+		return true
+	}
+	value, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	return err == nil && hmac.Equal(mac, o.sign(value, session))
+}
+
+func (o *CSRFOptions) sign(value, session string) []byte {
+	mac := hmac.New(sha256.New, o.Key)
+	mac.Write([]byte(session))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// maskCSRFToken returns pad followed by token XOR pad, for a random pad,
+// base64url-encoded.
+func maskCSRFToken(token string) string {
+	masked := make([]byte, 2*len(token))
+	pad := masked[:len(token)]
+	rand.Read(pad)
+	for i := 0; i < len(token); i++ {
+		masked[len(token)+i] = token[i] ^ pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// unmaskCSRFToken reverses maskCSRFToken. Tokens that are not masked, such
+// as a cookie value copied by a script, are returned as they are.
+func unmaskCSRFToken(token string) []byte {
+	masked, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(masked) == 0 || len(masked)%2 != 0 {
+		return []byte(token)
+	}
+	n := len(masked) / 2
+	for i := 0; i < n; i++ {
+		masked[n+i] ^= masked[i]
+	}
+	return masked[n:]
+}
+
+func setCSRFCookie(w http.ResponseWriter, opts CSRFOptions, token string) {
 	http.SetCookie(w, &http.Cookie{
-		Name:  "csrf_token",
-		Value: encodedToken,
-		Path:  "/",
+		Name:     opts.CookieName,
+		Value:    token,
+		Path:     opts.CookiePath,
+		Domain:   opts.CookieDomain,
+		MaxAge:   opts.CookieMaxAge,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HTTPOnly,
+		SameSite: opts.SameSite,
 	})
 }
 
+func containsMethod(methods []string, method string) bool {
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func csrfExempt(opts CSRFOptions, r *http.Request) bool {
+	if opts.Exempt != nil && opts.Exempt(r) {
+		return true
+	}
+	for _, pattern := range opts.ExemptPaths {
+		if strings.HasSuffix(pattern, "/") {
+			if strings.HasPrefix(r.URL.Path, pattern) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pattern, r.URL.Path); ok {
+			return true
+		}
+	}
+	return false
+}
+
 // Validation function using a third-party library
 var validate = validator.New()
 
@@ -0,0 +1,307 @@
+package router
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// csrfHandler wraps a handler that reports the token it was given, so tests
+// can feed it back into later requests.
+func csrfHandler(opts CSRFOptions) http.Handler {
+	return CSRF(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, TokenFromRequest(r))
+	}))
+}
+
+// csrfCookie fetches a page and returns the token cookie it set and the
+// masked token the page embedded.
+func csrfCookie(t *testing.T, h http.Handler, session string) (*http.Cookie, string) {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Session", session)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "csrf_token" {
+			return c, w.Body.String()
+		}
+	}
+	t.Fatal("GET set no token cookie")
+	return nil, ""
+}
+
+func csrfSession(r *http.Request) string {
+	return r.Header.Get("X-Session")
+}
+
+func TestCSRF(t *testing.T) {
+	for _, key := range [][]byte{nil, []byte("0123456789abcdef0123456789abcdef")} {
+		h := csrfHandler(CSRFOptions{Key: key, Session: csrfSession})
+		cookie, token := csrfCookie(t, h, "alice")
+		_, other := csrfCookie(t, h, "alice")
+
+		tests := []struct {
+			name   string
+			cookie string
+			header string
+			form   string
+			want   int
+		}{
+			{"masked header", cookie.Value, token, "", http.StatusOK},
+			{"masked form field", cookie.Value, "", token, http.StatusOK},
+			{"raw cookie value", cookie.Value, cookie.Value, "", http.StatusOK},
+			{"no cookie", "", token, "", http.StatusForbidden},
+			{"no token", cookie.Value, "", "", http.StatusForbidden},
+			{"token of another cookie", cookie.Value, other, "", http.StatusForbidden},
+			{"garbage token", cookie.Value, "not-a-token", "", http.StatusForbidden},
+			{"truncated token", cookie.Value, token[:len(token)-4], "", http.StatusForbidden},
+		}
+
+		for _, tt := range tests {
+			var body io.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{"csrf_token": {tt.form}}.Encode())
+			}
+			r := httptest.NewRequest("POST", "/", body)
+			r.Header.Set("X-Session", "alice")
+			if tt.form != "" {
+				r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set("X-CSRF-Token", tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("key %t, %s: status %d, want %d", key != nil, tt.name, w.Code, tt.want)
+			}
+		}
+	}
+}
+
+func TestCSRFMasking(t *testing.T) {
+	h := csrfHandler(CSRFOptions{})
+	cookie, _ := csrfCookie(t, h, "")
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	seen := map[string]bool{}
+	CSRF(CSRFOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 10; i++ {
+			token := TokenFromRequest(r)
+			if seen[token] || strings.Contains(token, cookie.Value) {
+				t.Errorf("TokenFromRequest repeated or exposed the token: %s", token)
+			}
+			seen[token] = true
+			if string(unmaskCSRFToken(token)) != cookie.Value {
+				t.Errorf("%s does not unmask to the cookie value", token)
+			}
+		}
+	})).ServeHTTP(httptest.NewRecorder(), r)
+
+	if TokenFromRequest(httptest.NewRequest("GET", "/", nil)) != "" {
+		t.Error("TokenFromRequest returned a token for a request outside CSRF")
+	}
+}
+
+func TestCSRFSignedCookies(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	h := csrfHandler(CSRFOptions{Key: key, Session: csrfSession})
+	alice, _ := csrfCookie(t, h, "alice")
+	_, bobToken := csrfCookie(t, h, "bob")
+	forged := (&CSRFOptions{Key: []byte("another key of thirty-two bytes!")}).newToken("alice")
+	unsigned := (&CSRFOptions{}).newToken("")
+
+	tests := []struct {
+		name    string
+		session string
+		cookie  string
+		token   string
+		want    int
+		// replaced is whether the cookie fails verification and is
+		// replaced, so the next page gets a valid one.
+		replaced bool
+	}{
+		{"own session", "alice", alice.Value, alice.Value, http.StatusOK, false},
+		{"cookie of another session", "bob", alice.Value, alice.Value, http.StatusForbidden, true},
+		{"token of another session", "alice", alice.Value, bobToken, http.StatusForbidden, false},
+		{"signed with another key", "alice", forged, forged, http.StatusForbidden, true},
+		{"planted unsigned cookie", "alice", unsigned, unsigned, http.StatusForbidden, true},
+		{"tampered signature", "alice", alice.Value + "A", alice.Value + "A", http.StatusForbidden, true},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("DELETE", "/", nil)
+		r.Header.Set("X-Session", tt.session)
+		r.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+		r.Header.Set("X-CSRF-Token", tt.token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		replaced := false
+		for _, c := range w.Result().Cookies() {
+			replaced = replaced || c.Name == "csrf_token"
+		}
+		if replaced != tt.replaced {
+			t.Errorf("%s: cookie replaced = %t", tt.name, replaced)
+		}
+	}
+}
+
+func TestRotateCSRFToken(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	opts := CSRFOptions{Key: key, Session: csrfSession}
+	h := csrfHandler(opts)
+	anonymous, _ := csrfCookie(t, h, "")
+
+	var rotated string
+	login := CSRF(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rotated = RotateCSRFToken(w, r, "alice")
+		if got := TokenFromRequest(r); string(unmaskCSRFToken(got)) != string(unmaskCSRFToken(rotated)) {
+			t.Error("TokenFromRequest after rotation returned the old token")
+		}
+	}))
+	r := httptest.NewRequest("POST", "/login", nil)
+	r.AddCookie(anonymous)
+	r.Header.Set("X-CSRF-Token", anonymous.Value)
+	w := httptest.NewRecorder()
+	login.ServeHTTP(w, r)
+
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "csrf_token" {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == anonymous.Value {
+		t.Fatalf("rotation set cookie %+v", cookie)
+	}
+	if !opts.verifyCookie(cookie.Value, "alice") || opts.verifyCookie(cookie.Value, "") {
+		t.Error("rotated cookie is not bound to the new session")
+	}
+
+	r = httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("X-Session", "alice")
+	r.AddCookie(cookie)
+	r.Header.Set("X-CSRF-Token", rotated)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("request with the rotated token: status %d", w.Code)
+	}
+
+	if got := RotateCSRFToken(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), "alice"); got != "" {
+		t.Errorf("RotateCSRFToken outside CSRF returned %q", got)
+	}
+}
+
+func TestCSRFOptions(t *testing.T) {
+	var failure error
+	opts := CSRFOptions{
+		SafeMethods: []string{"GET", "REPORT"},
+		CookieName:  "xsrf",
+		HeaderName:  "X-XSRF",
+		ExemptPaths: []string{"/hooks/", "/api/*/callback"},
+		Exempt:      func(r *http.Request) bool { return r.Header.Get("Authorization") != "" },
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			failure = err
+			w.WriteHeader(http.StatusTeapot)
+		},
+	}
+
+	tests := []struct {
+		method string
+		target string
+		header string
+		want   int
+		err    error
+	}{
+		{"REPORT", "/", "", http.StatusOK, nil},
+		{"HEAD", "/", "", http.StatusTeapot, ErrCSRFTokenMissing},
+		{"POST", "/hooks/github", "", http.StatusOK, nil},
+		{"POST", "/hooks", "", http.StatusTeapot, ErrCSRFTokenMissing},
+		{"POST", "/api/pay/callback", "", http.StatusOK, nil},
+		{"POST", "/api/pay/other", "", http.StatusTeapot, ErrCSRFTokenMissing},
+		{"POST", "/", "Bearer x", http.StatusOK, nil},
+	}
+
+	h := CSRF(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, tt := range tests {
+		failure = nil
+		r := httptest.NewRequest(tt.method, tt.target, nil)
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want || !errors.Is(failure, tt.err) {
+			t.Errorf("%s %s: status %d, error %v", tt.method, tt.target, w.Code, failure)
+		}
+		if c := w.Result().Cookies(); len(c) != 1 || c[0].Name != "xsrf" || c[0].SameSite != http.SameSiteLaxMode || c[0].Path != "/" {
+			t.Errorf("%s %s: cookies %+v", tt.method, tt.target, c)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "xsrf", Value: "abc"})
+	r.Header.Set("X-XSRF", "abd")
+	h.ServeHTTP(w, r)
+	if !errors.Is(failure, ErrCSRFTokenInvalid) {
+		t.Errorf("mismatched token: error %v, want ErrCSRFTokenInvalid", failure)
+	}
+}
+
+func TestCSRFCookieAttributes(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(w http.ResponseWriter)
+		want http.Cookie
+	}{
+		{
+			"defaults",
+			SetCSRFToken,
+			http.Cookie{Name: "csrf_token", Path: "/", SameSite: http.SameSiteLaxMode},
+		},
+		{
+			"options",
+			func(w http.ResponseWriter) {
+				opts := CSRFOptions{
+					CookieName:   "xsrf",
+					CookiePath:   "/app",
+					CookieDomain: "example.com",
+					CookieMaxAge: 3600,
+					Secure:       true,
+					HTTPOnly:     true,
+					SameSite:     http.SameSiteStrictMode,
+				}
+				CSRF(opts)(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+			},
+			http.Cookie{Name: "xsrf", Path: "/app", Domain: "example.com", MaxAge: 3600, Secure: true, HttpOnly: true, SameSite: http.SameSiteStrictMode},
+		},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.set(w)
+		cookies := w.Result().Cookies()
+		if len(cookies) != 1 {
+			t.Fatalf("%s: cookies %+v", tt.name, cookies)
+		}
+		c := cookies[0]
+		if c.Value == "" || c.Name != tt.want.Name || c.Path != tt.want.Path || c.Domain != tt.want.Domain ||
+			c.MaxAge != tt.want.MaxAge || c.Secure != tt.want.Secure || c.HttpOnly != tt.want.HttpOnly || c.SameSite != tt.want.SameSite {
+			t.Errorf("%s: cookie %+v", tt.name, c)
+		}
+	}
+}
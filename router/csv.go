@@ -0,0 +1,263 @@
+package router
+
+import (
+	"encoding"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// CSVEncoder renders a slice of structs, or a single struct, as CSV with a
+// header row. Column names come from csv tags, then json tags, then field
+// names. Nested structs are flattened into "parent.child" columns, values
+// implementing encoding.TextMarshaler (such as time.Time) are written as
+// text, and slices and maps inside a row are written as JSON. Slices of maps
+// use the sorted union of their keys as columns, and [][]string is written
+// as-is.
+var CSVEncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+	cw := csv.NewWriter(w)
+	if err := writeCSV(cw, reflect.ValueOf(v)); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+})
+
+// csvColumn is a flattened struct field.
+type csvColumn struct {
+	name  string
+	index [][]int // field index path through each nested struct
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+func writeCSV(cw *csv.Writer, v reflect.Value) error {
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return nil
+	}
+
+	rows := v
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		rows = reflect.MakeSlice(reflect.SliceOf(v.Type()), 1, 1)
+		rows.Index(0).Set(v)
+	}
+
+	if raw, ok := rows.Interface().([][]string); ok {
+		return cw.WriteAll(raw)
+	}
+
+	elemType := rows.Type().Elem()
+	for elemType.Kind() == reflect.Pointer || elemType.Kind() == reflect.Interface {
+		if elemType.Kind() == reflect.Interface {
+			return writeCSVDynamic(cw, rows)
+		}
+		elemType = elemType.Elem()
+	}
+
+	switch {
+	case elemType.Kind() == reflect.Struct && !isCSVLeaf(elemType):
+		return writeCSVStructs(cw, rows, csvColumns(elemType, "", nil, map[reflect.Type]bool{}))
+	case elemType.Kind() == reflect.Map && elemType.Key().Kind() == reflect.String:
+		return writeCSVMaps(cw, rows)
+	}
+	return fmt.Errorf("csv: cannot encode %s, want a slice of structs or maps", v.Type())
+}
+
+// writeCSVDynamic handles []interface{} by inspecting the first element.
+func writeCSVDynamic(cw *csv.Writer, rows reflect.Value) error {
+	if rows.Len() == 0 {
+		return nil
+	}
+	first := indirectValue(rows.Index(0))
+	if !first.IsValid() {
+		return errors.New("csv: row 0 is nil")
+	}
+	switch {
+	case first.Kind() == reflect.Struct:
+		for i := 1; i < rows.Len(); i++ {
+			if row := indirectValue(rows.Index(i)); row.IsValid() && row.Type() != first.Type() {
+				return fmt.Errorf("csv: row %d is %s, want %s like row 0", i, row.Type(), first.Type())
+			}
+		}
+		return writeCSVStructs(cw, rows, csvColumns(first.Type(), "", nil, map[reflect.Type]bool{}))
+	case first.Kind() == reflect.Map:
+		return writeCSVMaps(cw, rows)
+	}
+	return fmt.Errorf("csv: cannot encode rows of %s", first.Type())
+}
+
+// csvColumns flattens the fields of t into columns. Columns are derived from
+// the type alone, so every row has the same shape even when nested pointers
+// are nil. A struct field of a type already being flattened, as in a
+// self-referential type, is written as one cell rather than recursed into.
+func csvColumns(t reflect.Type, prefix string, path [][]int, visited map[reflect.Type]bool) []csvColumn {
+	visited[t] = true
+	defer delete(visited, t)
+
+	var columns []csvColumn
+	for _, f := range structFields(t, "csv", "json") {
+		name := prefix + f.name
+		fieldPath := append(append([][]int(nil), path...), f.index)
+
+		ft := f.typ
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && !isCSVLeaf(ft) && !visited[ft] {
+			columns = append(columns, csvColumns(ft, name+".", fieldPath, visited)...)
+			continue
+		}
+		columns = append(columns, csvColumn{name: name, index: fieldPath})
+	}
+	return columns
+}
+
+// isCSVLeaf reports whether values of t are written as a single cell.
+func isCSVLeaf(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+func writeCSVStructs(cw *csv.Writer, rows reflect.Value, columns []csvColumn) error {
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(columns))
+	for i := 0; i < rows.Len(); i++ {
+		row := indirectValue(rows.Index(i))
+		for j, col := range columns {
+			cell, err := csvCell(csvLookup(row, col.index))
+			if err != nil {
+				return fmt.Errorf("csv: row %d, column %s: %v", i, col.name, err)
+			}
+			record[j] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// csvLookup follows a column's index path, returning an invalid Value when a
+// nil pointer is met along the way.
+func csvLookup(v reflect.Value, path [][]int) reflect.Value {
+	for _, index := range path {
+		v = indirectValue(v)
+		if !v.IsValid() {
+			return v
+		}
+		field, ok := fieldByIndex(v, index)
+		if !ok {
+			return reflect.Value{}
+		}
+		v = field
+	}
+	return v
+}
+
+func writeCSVMaps(cw *csv.Writer, rows reflect.Value) error {
+	seen := map[string]bool{}
+	var header []string
+	for i := 0; i < rows.Len(); i++ {
+		row := indirectValue(rows.Index(i))
+		if row.Kind() != reflect.Map {
+			return fmt.Errorf("csv: row %d is %s, not a map", i, row.Kind())
+		}
+		if row.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("csv: row %d is %s, want string keys", i, row.Type())
+		}
+		for _, key := range row.MapKeys() {
+			if name := key.String(); !seen[name] {
+				seen[name] = true
+				header = append(header, name)
+			}
+		}
+	}
+	sort.Strings(header)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	record := make([]string, len(header))
+	for i := 0; i < rows.Len(); i++ {
+		row := indirectValue(rows.Index(i))
+		for j, name := range header {
+			value := row.MapIndex(reflect.ValueOf(name).Convert(row.Type().Key()))
+			cell, err := csvCell(value)
+			if err != nil {
+				return fmt.Errorf("csv: row %d, column %s: %v", i, name, err)
+			}
+			record[j] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func csvCell(v reflect.Value) (string, error) {
+	if v.IsValid() && v.CanInterface() {
+		if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+			if v.Kind() == reflect.Pointer && v.IsNil() {
+				return "", nil
+			}
+			text, err := tm.MarshalText()
+			return string(text), err
+		}
+	}
+
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return "", nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return "", nil
+	}
+	if !v.CanInterface() {
+		return fmt.Sprint(v), nil
+	}
+	if tm, ok := v.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	data, err := json.Marshal(v.Interface())
+	return string(data), err
+}
+
+// indirectValue follows pointers and interfaces, returning an invalid Value
+// for nil.
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,99 @@
+package router
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type csvAddress struct {
+	City string `json:"city"`
+}
+
+type csvRow struct {
+	ID      int               `json:"id"`
+	Name    string            `csv:"full_name" json:"name"`
+	Placed  time.Time         `json:"placed"`
+	Address *csvAddress       `json:"address"`
+	Tags    []string          `json:"tags"`
+	Attrs   map[string]string `json:"attrs"`
+	Skip    string            `json:"-"`
+}
+
+// csvNode refers to its own type, which is written as one cell rather
+// than flattened.
+type csvNode struct {
+	Name  string   `json:"name"`
+	Child *csvNode `json:"child"`
+}
+
+func TestCSVEncoder(t *testing.T) {
+	placed := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{
+			"structs",
+			[]csvRow{
+				{ID: 1, Name: "Ann, Jr.", Placed: placed, Address: &csvAddress{"Oslo"}, Tags: []string{"a"}, Skip: "x"},
+				{ID: 2, Name: "Bob"},
+			},
+			"id,full_name,placed,address.city,tags,attrs\n" +
+				"1,\"Ann, Jr.\",2024-05-01T12:00:00Z,Oslo,\"[\"\"a\"\"]\",\n" +
+				"2,Bob,0001-01-01T00:00:00Z,,,\n",
+		},
+		{"single struct", csvAddress{"Rome"}, "city\nRome\n"},
+		{
+			"recursive type",
+			[]csvNode{{Name: "a", Child: &csvNode{Name: "b"}}, {Name: "c"}},
+			"name,child\na,\"{\"\"name\"\":\"\"b\"\",\"\"child\"\":null}\"\nc,\n",
+		},
+		{"pointers", []*csvAddress{{"Rome"}, nil}, "city\nRome\n\n"},
+		{
+			"maps",
+			[]map[string]interface{}{{"b": 1, "a": "x"}, {"c": true}},
+			"a,b,c\nx,1,\n,,true\n",
+		},
+		{"dynamic structs", []interface{}{csvAddress{"Rome"}, &csvAddress{"Oslo"}}, "city\nRome\nOslo\n"},
+		{"dynamic maps", []interface{}{map[string]int{"n": 1}}, "n\n1\n"},
+		{"raw", [][]string{{"a", "b"}, {"1", "2"}}, "a,b\n1,2\n"},
+		{"empty", []csvRow{}, "id,full_name,placed,address.city,tags,attrs\n"},
+		{"nil", nil, ""},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := CSVEncoder.Encode(&buf, tt.v); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestCSVEncoderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"scalar", 42},
+		{"scalars", []int{1, 2}},
+		{"int keys", []map[int]string{{1: "a"}}},
+		{"dynamic int keys", []interface{}{map[int]string{1: "a"}}},
+		{"mixed rows", []interface{}{csvAddress{"Rome"}, map[string]string{"city": "Oslo"}}},
+		{"mixed structs", []interface{}{csvAddress{"Rome"}, csvRow{}}},
+		{"nil first row", []interface{}{nil, csvAddress{"Rome"}}},
+		{"bad cell", []map[string]interface{}{{"f": func() {}}}},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := CSVEncoder.Encode(&buf, tt.v); err == nil {
+			t.Errorf("%s: encoding succeeded with %q, want an error", tt.name, buf.String())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package router
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecompressOptions configures the Decompress middleware.
+type DecompressOptions struct {
+	// MaxSize caps the decompressed size of a request body in bytes.
+	// Reading past it fails with an *http.MaxBytesError. Defaults to 10 MB.
+	MaxSize int64
+}
+
+// Decompress returns middleware that transparently decodes request bodies
+// sent with Content-Encoding gzip or deflate, so binders see the plain body.
+// Requests using any other coding are rejected with 415 Unsupported Media
+// Type, and malformed compressed bodies with 400 Bad Request.
+func Decompress(opts DecompressOptions) Middleware {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 10 << 20
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			codings := contentCodings(r.Header.Get("Content-Encoding"))
+			if len(codings) == 0 || r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, coding := range codings {
+				if coding != "gzip" && coding != "x-gzip" && coding != "deflate" {
+					w.Header().Set("Accept-Encoding", "gzip, deflate")
+					http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+					return
+				}
+			}
+
+			// Codings are listed in the order they were applied, so they
+			// are removed in reverse.
+			body := r.Body
+			var reader io.Reader = body
+			for i := len(codings) - 1; i >= 0; i-- {
+				var err error
+				if reader, err = decompressReader(codings[i], reader); err != nil {
+					http.Error(w, "Malformed request body encoding", http.StatusBadRequest)
+					return
+				}
+			}
+
+			r.Body = &decompressBody{
+				reader:    reader,
+				body:      body,
+				remaining: opts.MaxSize,
+				limit:     opts.MaxSize,
+			}
+			r.ContentLength = -1
+			r.Header.Del("Content-Encoding")
+			r.Header.Del("Content-Length")
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// contentCodings lists the codings in a Content-Encoding header, ignoring
+// identity.
+func contentCodings(header string) []string {
+	var codings []string
+	for _, coding := range strings.Split(header, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+func decompressReader(coding string, r io.Reader) (io.Reader, error) {
+	if coding != "deflate" {
+		return gzip.NewReader(r)
+	}
+
+	// "deflate" is meant to be zlib-wrapped, but some clients send a raw
+	// deflate stream. Tell them apart by the zlib header checksum.
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
+
+// decompressBody enforces the decompressed size limit and closes the
+// original body.
+type decompressBody struct {
+	reader    io.Reader
+	body      io.Closer
+	remaining int64
+	limit     int64
+}
+
+func (d *decompressBody) Read(p []byte) (int, error) {
+	if d.remaining <= 0 {
+		// Probe for one more byte so a body of exactly the limit is allowed.
+		var probe [1]byte
+		n, err := d.reader.Read(probe[:])
+		if n > 0 {
+			return 0, &http.MaxBytesError{Limit: d.limit}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.reader.Read(p)
+	d.remaining -= int64(n)
+	return n, err
+}
+
+func (d *decompressBody) Close() error {
+	if c, ok := d.reader.(io.Closer); ok {
+		c.Close()
+	}
+	return d.body.Close()
+}
@@ -0,0 +1,140 @@
+package router
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func gzipped(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func zlibbed(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func deflated(data []byte) []byte {
+	var buf bytes.Buffer
+	zw, _ := flate.NewWriter(&buf, flate.DefaultCompression)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// echoBody answers with the request body it received, or with the read
+// error when reading fails.
+var echoBody = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if encoding := r.Header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		http.Error(w, "encoding headers left in place", http.StatusTeapot)
+		return
+	}
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write(data)
+})
+
+func TestDecompress(t *testing.T) {
+	plain := []byte(`{"name":"` + strings.Repeat("a", 100) + `"}`)
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+		want     string
+	}{
+		{"plain", "", plain, http.StatusOK, string(plain)},
+		{"identity", "identity", plain, http.StatusOK, string(plain)},
+		{"gzip", "gzip", gzipped(plain), http.StatusOK, string(plain)},
+		{"x-gzip", "X-GZIP", gzipped(plain), http.StatusOK, string(plain)},
+		{"zlib deflate", "deflate", zlibbed(plain), http.StatusOK, string(plain)},
+		{"raw deflate", "deflate", deflated(plain), http.StatusOK, string(plain)},
+		// Codings are listed in the order they were applied.
+		{"stacked", "gzip, deflate", zlibbed(gzipped(plain)), http.StatusOK, string(plain)},
+		{"unsupported", "br", plain, http.StatusUnsupportedMediaType, "Unsupported Media Type\n"},
+		{"unsupported in a list", "gzip, br", plain, http.StatusUnsupportedMediaType, "Unsupported Media Type\n"},
+		{"bad gzip header", "gzip", plain, http.StatusBadRequest, "Malformed request body encoding\n"},
+		{"truncated gzip", "gzip", gzipped(plain)[:30], http.StatusBadRequest, "unexpected EOF\n"},
+	}
+
+	h := Decompress(DecompressOptions{})(echoBody)
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(tt.body))
+		if tt.encoding != "" {
+			r.Header.Set("Content-Encoding", tt.encoding)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.status || w.Body.String() != tt.want {
+			t.Errorf("%s: %d %q", tt.name, w.Code, w.Body)
+		}
+		if tt.status == http.StatusUnsupportedMediaType && w.Header().Get("Accept-Encoding") != "gzip, deflate" {
+			t.Errorf("%s: Accept-Encoding = %q", tt.name, w.Header().Get("Accept-Encoding"))
+		}
+	}
+}
+
+func TestDecompressMaxSize(t *testing.T) {
+	tests := []struct {
+		size   int
+		status int
+	}{
+		{99, http.StatusOK},
+		{100, http.StatusOK},
+		{101, http.StatusRequestEntityTooLarge},
+		// A small compressed body can expand far past the limit.
+		{10 << 20, http.StatusRequestEntityTooLarge},
+	}
+
+	h := Decompress(DecompressOptions{MaxSize: 100})(echoBody)
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/", bytes.NewReader(gzipped(make([]byte, tt.size))))
+		r.Header.Set("Content-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%d bytes: status %d, want %d", tt.size, w.Code, tt.status)
+		}
+	}
+}
+
+func TestDecompressBind(t *testing.T) {
+	var got struct {
+		Name string `json:"name"`
+	}
+	h := Decompress(DecompressOptions{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := Bind(r, &got); err != nil {
+			t.Error(err)
+		}
+	}))
+	r := httptest.NewRequest("POST", "/", bytes.NewReader(gzipped([]byte(`{"name":"zipped"}`))))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if got.Name != "zipped" {
+		t.Errorf("bound %+v", got)
+	}
+}
@@ -0,0 +1,305 @@
+package router
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const diskEntrySuffix = ".cache"
+
+// DiskStoreOptions configures a DiskStore.
+type DiskStoreOptions struct {
+	// Dir is the directory holding the cache files. It is created if needed.
+	Dir string
+	// MaxBytes caps the total size of the cache files. When a write pushes
+	// the store over the quota, expired entries are removed first and then
+	// the least recently used ones. Zero means no limit.
+	MaxBytes int64
+}
+
+// DiskStore is a CacheStore that keeps one file per entry in a directory, so
+// cached responses survive restarts. Files are written atomically by renaming
+// a completed temporary file into place.
+type DiskStore struct {
+	opts  DiskStoreOptions
+	mu    sync.Mutex
+	index map[string]*diskEntry // keyed by file name
+	size  int64
+}
+
+// diskEntry is the in-memory index record for a cache file. Its entry holds
+// everything except the body.
+type diskEntry struct {
+	key        string
+	entry      CacheEntry
+	size       int64
+	lastAccess time.Time
+}
+
+// diskMeta is the gob-encoded header at the start of each cache file. The
+// body follows it unencoded.
+type diskMeta struct {
+	Key   string
+	Entry CacheEntry
+}
+
+// NewDiskStore opens the store in opts.Dir and indexes the entries already
+// present there.
+func NewDiskStore(opts DiskStoreOptions) (*DiskStore, error) {
+	if opts.Dir == "" {
+		return nil, errors.New("disk store: directory is required")
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	s := &DiskStore{opts: opts, index: make(map[string]*diskEntry)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load rebuilds the index from the directory, discarding leftover temporary
+// files and entries that cannot be read.
+func (s *DiskStore) load() error {
+	files, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(s.opts.Dir, name)
+		if strings.HasPrefix(name, ".tmp-") {
+			os.Remove(path)
+			continue
+		}
+		if file.IsDir() || !strings.HasSuffix(name, diskEntrySuffix) {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+		meta, err := readDiskMeta(path)
+		if err != nil {
+			os.Remove(path)
+			continue
+		}
+		s.index[name] = &diskEntry{
+			key:        meta.Key,
+			entry:      meta.Entry,
+			size:       info.Size(),
+			lastAccess: info.ModTime(),
+		}
+		s.size += info.Size()
+	}
+	return nil
+}
+
+func (s *DiskStore) Get(key string) (*CacheEntry, error) {
+	name := diskFileName(key)
+
+	s.mu.Lock()
+	de, exists := s.index[name]
+	if exists && time.Now().After(de.entry.retainUntil()) {
+		s.remove(name)
+		exists = false
+	}
+	if exists {
+		de.lastAccess = time.Now()
+	}
+	s.mu.Unlock()
+	if !exists {
+		return nil, ErrCacheMiss
+	}
+
+	f, err := os.Open(filepath.Join(s.opts.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		s.mu.Lock()
+		s.remove(name)
+		s.mu.Unlock()
+		return nil, ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	meta, err := decodeDiskMeta(br)
+	if err != nil {
+		return nil, fmt.Errorf("disk store: %s: %v", name, err)
+	}
+	if meta.Key != key {
+		return nil, ErrCacheMiss
+	}
+	body, err := io.ReadAll(br)
+	if err != nil {
+		return nil, err
+	}
+	entry := meta.Entry
+	entry.Body = body
+	return &entry, nil
+}
+
+func (s *DiskStore) Set(key string, entry *CacheEntry) error {
+	tmp, err := os.CreateTemp(s.opts.Dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	size, err := writeDiskEntry(tmp, key, entry)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	name := diskFileName(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Rename(tmp.Name(), filepath.Join(s.opts.Dir, name)); err != nil {
+		return err
+	}
+	if old, exists := s.index[name]; exists {
+		s.size -= old.size
+	}
+	meta := *entry
+	meta.Body = nil
+	s.index[name] = &diskEntry{key: key, entry: meta, size: size, lastAccess: time.Now()}
+	s.size += size
+	s.evict()
+	return nil
+}
+
+func (s *DiskStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(diskFileName(key))
+}
+
+func (s *DiskStore) DeleteFunc(match func(key string, entry *CacheEntry) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var firstErr error
+	for name, de := range s.index {
+		if match(de.key, &de.entry) {
+			if err := s.remove(name); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// evict enforces MaxBytes. The caller must hold s.mu.
+func (s *DiskStore) evict() {
+	if s.opts.MaxBytes <= 0 || s.size <= s.opts.MaxBytes {
+		return
+	}
+
+	now := time.Now()
+	names := make([]string, 0, len(s.index))
+	for name, de := range s.index {
+		if now.After(de.entry.retainUntil()) {
+			s.remove(name)
+			continue
+		}
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		return s.index[names[i]].lastAccess.Before(s.index[names[j]].lastAccess)
+	})
+	for _, name := range names {
+		if s.size <= s.opts.MaxBytes {
+			return
+		}
+		s.remove(name)
+	}
+}
+
+// remove deletes a cache file and its index record. The caller must hold s.mu.
+func (s *DiskStore) remove(name string) error {
+	if de, exists := s.index[name]; exists {
+		s.size -= de.size
+		delete(s.index, name)
+	}
+	err := os.Remove(filepath.Join(s.opts.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func diskFileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]) + diskEntrySuffix
+}
+
+// writeDiskEntry writes a length-prefixed metadata header followed by the
+// body and returns the number of bytes written.
+func writeDiskEntry(w io.Writer, key string, entry *CacheEntry) (int64, error) {
+	meta := diskMeta{Key: key, Entry: *entry}
+	meta.Entry.Body = nil
+
+	var header strings.Builder
+	if err := gob.NewEncoder(&header).Encode(meta); err != nil {
+		return 0, err
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(header.Len()))
+	n := int64(0)
+	for _, chunk := range [][]byte{length[:], []byte(header.String()), entry.Body} {
+		written, err := w.Write(chunk)
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func readDiskMeta(path string) (*diskMeta, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decodeDiskMeta(bufio.NewReader(f))
+}
+
+func decodeDiskMeta(r io.Reader) (*diskMeta, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	var meta diskMeta
+	header := io.LimitReader(r, int64(binary.BigEndian.Uint32(length[:])))
+	if err := gob.NewDecoder(header).Decode(&meta); err != nil {
+		return nil, err
+	}
+	return &meta, nil
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiskStore(t *testing.T) {
+	store, err := NewDiskStore(DiskStoreOptions{Dir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCacheStore(t, store)
+}
+
+func TestDiskStoreReopen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(DiskStoreOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &CacheEntry{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"1"`}},
+		Body:       []byte("persisted"),
+		Expires:    time.Now().Add(time.Minute),
+		Tags:       []string{"users"},
+	}
+	if err := store.Set("/users", entry); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leftovers of an interrupted write and unreadable entries are removed.
+	os.WriteFile(filepath.Join(dir, ".tmp-123"), []byte("partial"), 0o600)
+	os.WriteFile(filepath.Join(dir, diskFileName("/broken")), []byte("junk"), 0o600)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("kept"), 0o600)
+
+	reopened, err := NewDiskStore(DiskStoreOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := reopened.Get("/users")
+	if err != nil || string(got.Body) != "persisted" || got.Header.Get("Etag") != `"1"` {
+		t.Fatalf("Get after reopening = %+v, %v", got, err)
+	}
+	var tagged []string
+	reopened.DeleteFunc(func(key string, e *CacheEntry) bool {
+		if e.Body != nil {
+			t.Errorf("DeleteFunc read the body of %s", key)
+		}
+		tagged = append(tagged, key+":"+strings.Join(e.Tags, ","))
+		return false
+	})
+	if len(tagged) != 1 || tagged[0] != "/users:users" {
+		t.Errorf("index after reopening holds %v", tagged)
+	}
+
+	files, _ := os.ReadDir(dir)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	if len(names) != 2 {
+		t.Errorf("files after reopening: %v, want the entry and notes.txt", names)
+	}
+}
+
+func TestDiskStoreMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	body := strings.Repeat("x", 1000)
+	store, err := NewDiskStore(DiskStoreOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := func(key string, expires time.Duration) {
+		t.Helper()
+		err := store.Set(key, &CacheEntry{StatusCode: http.StatusOK, Body: []byte(body), Expires: time.Now().Add(expires)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	set("/a", time.Minute)
+	// Leave room for two entries.
+	quota := store.size * 5 / 2
+	store.opts.MaxBytes = quota
+	time.Sleep(2 * time.Millisecond)
+	set("/b", time.Minute)
+	time.Sleep(2 * time.Millisecond)
+	store.Get("/a") // /b is now the least recently used
+	time.Sleep(2 * time.Millisecond)
+	set("/c", time.Minute)
+
+	for key, kept := range map[string]bool{"/a": true, "/b": false, "/c": true} {
+		_, err := store.Get(key)
+		if kept != (err == nil) {
+			t.Errorf("Get(%s) error = %v, kept = %t", key, err, kept)
+		}
+	}
+	if store.size > quota {
+		t.Errorf("store holds %d bytes over a %d byte quota", store.size, quota)
+	}
+
+	// Expired entries go before live ones.
+	set("/old", -time.Second)
+	for _, key := range []string{"/a", "/c"} {
+		if _, err := store.Get(key); err != nil {
+			t.Errorf("live entry %s was evicted before an expired one: %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, diskFileName("/old"))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired entry still on disk: %v", err)
+	}
+}
+
+func TestNewDiskStoreRequiresDir(t *testing.T) {
+	if _, err := NewDiskStore(DiskStoreOptions{}); err == nil {
+		t.Error("NewDiskStore without a directory succeeded")
+	}
+}
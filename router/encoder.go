@@ -0,0 +1,103 @@
+package router
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// Encoder writes a value to w in one media type. Respond hands encoders the
+// response writer directly, so large values are streamed rather than
+// buffered.
+type Encoder interface {
+	Encode(w io.Writer, v interface{}) error
+}
+
+// EncoderFunc adapts an ordinary function to the Encoder interface.
+type EncoderFunc func(w io.Writer, v interface{}) error
+
+// Encode calls f(w, v).
+func (f EncoderFunc) Encode(w io.Writer, v interface{}) error {
+	return f(w, v)
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]Encoder{}
+	// encoderTypes keeps registration order, which is the order of
+	// preference when a client accepts several types equally.
+	encoderTypes []string
+)
+
+// Built-in encoders, registered for application/json, application/xml and
+// text/plain. These and the other built-ins can be registered again under
+// vendor media types.
+var (
+	JSONEncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	})
+	XMLEncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		return enc.Encode(v)
+	})
+	TextEncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+		var err error
+		switch t := v.(type) {
+		case []byte:
+			_, err = w.Write(t)
+		case string:
+			_, err = io.WriteString(w, t)
+		default:
+			_, err = fmt.Fprint(w, v)
+		}
+		return err
+	})
+)
+
+func init() {
+	RegisterEncoder("application/json", JSONEncoder)
+	RegisterEncoder("application/xml", XMLEncoder)
+	RegisterEncoder("text/plain", TextEncoder)
+	RegisterEncoder("application/yaml", YAMLEncoder)
+	RegisterEncoder("text/csv", CSVEncoder)
+	RegisterEncoder("application/x-ndjson", NDJSONEncoder)
+	RegisterEncoder("application/msgpack", MsgPackEncoder)
+	RegisterEncoder("application/x-msgpack", MsgPackEncoder)
+	RegisterEncoder("application/cbor", CBOREncoder)
+	RegisterEncoder("application/vnd.api+json", NewJSONAPIEncoder(nil))
+	RegisterEncoder("application/hal+json", NewHALEncoder(nil))
+}
+
+// RegisterEncoder makes enc available to Respond and content negotiation
+// for mediaType. Registering a media type again replaces its encoder but
+// keeps its original preference.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	mediaType = strings.ToLower(mediaType)
+
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+	if _, exists := encoders[mediaType]; !exists {
+		encoderTypes = append(encoderTypes, mediaType)
+	}
+	encoders[mediaType] = enc
+}
+
+func encoderFor(mediaType string) (Encoder, bool) {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	enc, exists := encoders[mediaType]
+	return enc, exists
+}
+
+// encoderMediaTypes returns the registered media types in order of preference.
+func encoderMediaTypes() []string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+	return append([]string(nil), encoderTypes...)
+}
@@ -0,0 +1,111 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestRespond(t *testing.T) {
+	type item struct {
+		Name string `json:"name" xml:"name"`
+	}
+	tests := []struct {
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"", http.StatusCreated, "application/json", "{\n  \"name\": \"a\"\n}\n"},
+		{"application/xml", http.StatusCreated, "application/xml", "<item>\n  <name>a</name>\n</item>"},
+		{"text/plain", http.StatusCreated, "text/plain; charset=utf-8", "{a}"},
+		{"application/yaml", http.StatusCreated, "application/yaml", "name: a\n"},
+		{"image/png", http.StatusNotAcceptable, "text/plain; charset=utf-8", "Not Acceptable\n"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		Respond(w, r, http.StatusCreated, item{"a"})
+		if w.Code != tt.status || w.Header().Get("Content-Type") != tt.contentType || w.Body.String() != tt.body {
+			t.Errorf("Accept %q: %d %s %q", tt.accept, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		if w.Header().Get("Vary") != "Accept" {
+			t.Errorf("Accept %q: Vary = %q", tt.accept, w.Header().Get("Vary"))
+		}
+	}
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	const mediaType = "application/vnd.router-test+json"
+	RegisterEncoder("Application/Vnd.Router-Test+JSON", EncoderFunc(func(w io.Writer, v interface{}) error {
+		_, err := fmt.Fprintf(w, "v1:%v", v)
+		return err
+	}))
+	before := encoderMediaTypes()
+	RegisterEncoder(mediaType, EncoderFunc(func(w io.Writer, v interface{}) error {
+		_, err := fmt.Fprintf(w, "v2:%v", v)
+		return err
+	}))
+	if after := encoderMediaTypes(); !reflect.DeepEqual(after, before) {
+		t.Errorf("registering %s again changed the preference order: %v", mediaType, after)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", mediaType)
+	w := httptest.NewRecorder()
+	Respond(w, r, http.StatusOK, 7)
+	if w.Header().Get("Content-Type") != mediaType || w.Body.String() != "v2:7" {
+		t.Errorf("got %s %q", w.Header().Get("Content-Type"), w.Body)
+	}
+}
+
+func TestRespondEncoderErrors(t *testing.T) {
+	RegisterEncoder("application/vnd.router-test-fail-early", EncoderFunc(func(w io.Writer, v interface{}) error {
+		return errors.New("cannot encode")
+	}))
+	RegisterEncoder("application/vnd.router-test-fail-late", EncoderFunc(func(w io.Writer, v interface{}) error {
+		io.WriteString(w, "partial")
+		return errors.New("cannot encode")
+	}))
+	tests := []struct {
+		accept string
+		status int
+		body   string
+	}{
+		// Nothing was written, so the failure can still be reported.
+		{"application/vnd.router-test-fail-early", http.StatusInternalServerError, "Internal server error\n"},
+		// The status line is already out; the response is cut short.
+		{"application/vnd.router-test-fail-late", http.StatusAccepted, "partial"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept", tt.accept)
+		w := httptest.NewRecorder()
+		Respond(w, r, http.StatusAccepted, 1)
+		if w.Code != tt.status || w.Body.String() != tt.body {
+			t.Errorf("%s: %d %q", tt.accept, w.Code, w.Body)
+		}
+	}
+}
+
+func TestRespondNegotiatedType(t *testing.T) {
+	h := ContentNegotiationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A later change to the Accept header does not undo the decision.
+		r.Header.Set("Accept", "application/json")
+		Respond(w, r, http.StatusOK, "x")
+	}))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Body.String() != "x" || w.Header().Values("Vary")[0] != "Accept" || len(w.Header().Values("Vary")) != 1 {
+		t.Errorf("got %q, Vary %q", w.Body, w.Header().Values("Vary"))
+	}
+}
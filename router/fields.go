@@ -0,0 +1,133 @@
+package router
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// structField describes an exported struct field as it appears when a
+// struct is encoded or bound.
+type structField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+	options   []string
+}
+
+type fieldsKey struct {
+	typ  reflect.Type
+	tags string
+}
+
+var fieldsCache sync.Map // fieldsKey -> []structField
+
+// structFields lists the fields of struct type t named by the first of tags
+// that is present on each field, falling back to the Go field name. Fields
+// tagged "-" and unexported fields are skipped. Untagged embedded structs are
+// flattened into their parent, as encoding/json does.
+func structFields(t reflect.Type, tags ...string) []structField {
+	key := fieldsKey{typ: t, tags: strings.Join(tags, ",")}
+	if cached, ok := fieldsCache.Load(key); ok {
+		return cached.([]structField)
+	}
+
+	fields := collectFields(t, tags, nil, map[reflect.Type]bool{})
+	fieldsCache.Store(key, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, tags []string, index []int, visited map[reflect.Type]bool) []structField {
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	var fields []structField
+	seen := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, tagged := lookupTag(sf, tags)
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		fieldIndex := append(append([]int(nil), index...), i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && !tagged && ft.Kind() == reflect.Struct {
+			for _, f := range collectFields(ft, tags, fieldIndex, visited) {
+				if !seen[f.name] {
+					seen[f.name] = true
+					fields = append(fields, f)
+				}
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		var options []string
+		if opts != "" {
+			options = strings.Split(opts, ",")
+		}
+		f := structField{
+			name:    name,
+			index:   fieldIndex,
+			typ:     sf.Type,
+			options: options,
+		}
+		for _, opt := range options {
+			if opt == "omitempty" {
+				f.omitEmpty = true
+			}
+		}
+		if seen[name] {
+			// A field declared directly on the struct wins over a
+			// promoted one with the same name.
+			for j := range fields {
+				if fields[j].name == name && len(fields[j].index) > len(f.index) {
+					fields[j] = f
+				}
+			}
+			continue
+		}
+		seen[name] = true
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+// lookupTag returns the value of the first of tags present on sf.
+func lookupTag(sf reflect.StructField, tags []string) (string, bool) {
+	for _, tag := range tags {
+		if value, ok := sf.Tag.Lookup(tag); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but reports false instead
+// of panicking when it meets a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
@@ -0,0 +1,272 @@
+package router
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	xmlNameType       = reflect.TypeOf(xml.Name{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// maxPrunedTypes bounds the number of struct types built for field subsets.
+// The runtime keeps every type made by reflect.StructOf, so past the bound
+// subsets share one sparse type per original type instead. Encoders render
+// sparse types like the others, except that CSV keeps every column.
+const maxPrunedTypes = 4096
+
+// prunedStruct is a struct type holding a subset of another struct's fields.
+type prunedStruct struct {
+	typ     reflect.Type
+	indexes [][]int // index in the original type of each field
+	// fields describes the original field behind each field, with a zero
+	// entry for XMLName.
+	fields []structField
+	// xmlName is the position of an added XMLName field, or -1 when the
+	// original type declares its own.
+	xmlName int
+	// sparse is set for the shared type of an original type, which has
+	// every field as an omitempty interface{}; fields not kept stay nil.
+	sparse bool
+}
+
+type prunedKey struct {
+	typ    reflect.Type
+	fields string
+	sparse bool
+}
+
+var (
+	prunedCache   sync.Map // prunedKey -> *prunedStruct
+	prunedCount   atomic.Int64
+	prunedOrigins sync.Map // pruned reflect.Type -> original reflect.Type
+)
+
+// pruneFields returns a copy of data keeping only the named fields, matched
+// by json name. It handles structs, maps with string keys, pointers to them
+// and slices of them; the items of a PageEnvelope are pruned rather than the
+// envelope itself. Other values are returned unchanged.
+func pruneFields(data interface{}, fields []string) interface{} {
+	switch env := data.(type) {
+	case PageEnvelope:
+		env.Data = pruneItems(env.Data, fields, "data")
+		return env
+	case *PageEnvelope:
+		copied := *env
+		copied.Data = pruneItems(env.Data, fields, "data")
+		return copied
+	}
+	return pruneItems(data, fields, "")
+}
+
+// pruneItems prunes data, naming pruned structs xmlName in XML output. An
+// empty xmlName stands for the name of the original type.
+func pruneItems(data interface{}, fields []string, xmlName string) interface{} {
+	keep := make(map[string]bool, len(fields))
+	for _, name := range fields {
+		keep[name] = true
+	}
+	if pruned, ok := pruneValue(reflect.ValueOf(data), keep, xmlName); ok {
+		return pruned.Interface()
+	}
+	return data
+}
+
+func pruneValue(v reflect.Value, keep map[string]bool, xmlName string) (reflect.Value, bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return v, false
+		}
+		return pruneValue(v.Elem(), keep, xmlName)
+
+	case reflect.Struct:
+		if !prunable(v.Type()) {
+			return v, false
+		}
+		ps := prunedType(v.Type(), keep)
+		out := reflect.New(ps.typ).Elem()
+		for i, index := range ps.indexes {
+			if i == ps.xmlName {
+				name := xmlName
+				if name == "" {
+					name = v.Type().Name()
+				}
+				out.Field(i).Set(reflect.ValueOf(xml.Name{Local: name}))
+				continue
+			}
+			fv, ok := fieldByIndex(v, index)
+			if !ok {
+				continue
+			}
+			if f := ps.fields[i]; ps.sparse && f.name != "" && (!keep[f.name] || f.omitEmpty && isEmptyValue(fv)) {
+				continue
+			}
+			out.Field(i).Set(fv)
+		}
+		return out, true
+
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return v, false
+		}
+		if v.IsNil() {
+			return v, true
+		}
+		out := reflect.MakeMapWithSize(v.Type(), len(keep))
+		iter := v.MapRange()
+		for iter.Next() {
+			if keep[iter.Key().String()] {
+				out.SetMapIndex(iter.Key(), iter.Value())
+			}
+		}
+		return out, true
+
+	case reflect.Slice, reflect.Array:
+		elem := v.Type().Elem()
+		base := elem
+		if base.Kind() == reflect.Pointer {
+			base = base.Elem()
+		}
+		var prunedElem reflect.Type
+		switch {
+		case base.Kind() == reflect.Struct && prunable(base):
+			prunedElem = prunedType(base, keep).typ
+		case base.Kind() == reflect.Map && base.Key().Kind() == reflect.String:
+			prunedElem = base
+		default:
+			return v, false
+		}
+		if elem.Kind() == reflect.Pointer {
+			prunedElem = reflect.PointerTo(prunedElem)
+		}
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return reflect.Zero(reflect.SliceOf(prunedElem)), true
+		}
+
+		out := reflect.MakeSlice(reflect.SliceOf(prunedElem), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, ok := pruneValue(v.Index(i), keep, xmlName)
+			if !ok {
+				continue // nil pointer
+			}
+			if elem.Kind() == reflect.Pointer {
+				ptr := reflect.New(item.Type())
+				ptr.Elem().Set(item)
+				item = ptr
+			}
+			out.Index(i).Set(item)
+		}
+		return out, true
+	}
+	return v, false
+}
+
+// prunable reports whether a struct is encoded field by field, rather than
+// by its own marshaling method.
+func prunable(t reflect.Type) bool {
+	ptr := reflect.PointerTo(t)
+	return !ptr.Implements(jsonMarshalerType) && !ptr.Implements(textMarshalerType)
+}
+
+// prunedType returns a struct type with the fields of t named in keep. Kept
+// fields retain their Go names and tags, so every encoder renders them as
+// before. Unless t declares an XMLName field, one is added so that the
+// XML element name can be set at runtime: struct types built by reflection
+// have no name of their own.
+func prunedType(t reflect.Type, keep map[string]bool) *prunedStruct {
+	// Key on the fields t has, so unknown names do not make new types.
+	var names []string
+	for _, f := range structFields(t, "json") {
+		if keep[f.name] {
+			names = append(names, f.name)
+		}
+	}
+	key := prunedKey{typ: t, fields: strings.Join(names, ",")}
+	if cached, ok := prunedCache.Load(key); ok {
+		return cached.(*prunedStruct)
+	}
+	if prunedCount.Load() >= maxPrunedTypes {
+		key = prunedKey{typ: t, sparse: true}
+		if cached, ok := prunedCache.Load(key); ok {
+			return cached.(*prunedStruct)
+		}
+	}
+
+	ps := buildPrunedType(t, keep, key.sparse)
+	if cached, loaded := prunedCache.LoadOrStore(key, ps); loaded {
+		return cached.(*prunedStruct)
+	}
+	prunedCount.Add(1)
+	prunedOrigins.Store(ps.typ, t)
+	return ps
+}
+
+// buildPrunedType makes the struct type of prunedType. A sparse type keeps
+// every field of t, typed interface{} and tagged omitempty.
+func buildPrunedType(t reflect.Type, keep map[string]bool, sparse bool) *prunedStruct {
+	var fields []reflect.StructField
+	ps := &prunedStruct{xmlName: -1, sparse: sparse}
+	used := map[string]bool{"XMLName": true}
+
+	if sf, ok := t.FieldByName("XMLName"); ok && sf.Type == xmlNameType {
+		tag := sf.Tag
+		if _, ok := tag.Lookup("json"); !ok {
+			tag = `json:"-" ` + tag
+		}
+		fields = append(fields, reflect.StructField{Name: "XMLName", Type: xmlNameType, Tag: tag})
+		ps.indexes = append(ps.indexes, sf.Index)
+	} else {
+		fields = append(fields, reflect.StructField{Name: "XMLName", Type: xmlNameType, Tag: `json:"-"`})
+		ps.indexes = append(ps.indexes, nil)
+		ps.xmlName = 0
+	}
+	ps.fields = append(ps.fields, structField{})
+
+	for _, f := range structFields(t, "json") {
+		if !sparse && !keep[f.name] || f.typ == xmlNameType {
+			continue
+		}
+		sf := fieldType(t, f.index)
+		name := sf.Name
+		for used[name] {
+			// Fields promoted from different embedded structs can share
+			// a Go name.
+			name += "_"
+		}
+		used[name] = true
+		field := reflect.StructField{Name: name, Type: f.typ, Tag: sf.Tag}
+		if sparse {
+			field.Type = reflect.TypeOf((*interface{})(nil)).Elem()
+			field.Tag = omitEmptyTag(sf.Tag)
+		}
+		fields = append(fields, field)
+		ps.indexes = append(ps.indexes, f.index)
+		ps.fields = append(ps.fields, f)
+	}
+
+	ps.typ = reflect.StructOf(fields)
+	return ps
+}
+
+// omitEmptyTag adds omitempty to the json key of tag.
+func omitEmptyTag(tag reflect.StructTag) reflect.StructTag {
+	value, ok := tag.Lookup("json")
+	if !ok {
+		return `json:",omitempty" ` + tag
+	}
+	return reflect.StructTag(strings.Replace(string(tag), `json:"`+value+`"`, `json:"`+value+`,omitempty"`, 1))
+}
+
+// prunedOrigin returns the type t was pruned from, or t itself.
+func prunedOrigin(t reflect.Type) reflect.Type {
+	if origin, ok := prunedOrigins.Load(t); ok {
+		return origin.(reflect.Type)
+	}
+	return t
+}
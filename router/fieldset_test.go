@@ -0,0 +1,117 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type fieldsetOrder struct {
+	ID     int      `json:"id" resource:"id"`
+	Email  string   `json:"email"`
+	Total  float64  `json:"total" xml:"total,attr"`
+	Notes  string   `json:"notes,omitempty"`
+	Tags   []string `json:"tags"`
+	secret string
+}
+
+func respondFields(t *testing.T, accept string, data interface{}, fields ...string) string {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	Respond(w, r, 200, data, WithFields(fields...))
+	return w.Body.String()
+}
+
+func TestWithFields(t *testing.T) {
+	order := fieldsetOrder{ID: 1, Email: "a@example.com", Total: 9.5, Tags: []string{"x"}, secret: "s"}
+	tests := []struct {
+		accept string
+		data   interface{}
+		fields []string
+		want   string
+	}{
+		{"application/json", order, []string{"id", "email"}, `{"id":1,"email":"a@example.com"}`},
+		{"application/json", &order, []string{"total", "nope"}, `{"total":9.5}`},
+		{"application/json", []fieldsetOrder{order, order}, []string{"id"}, `[{"id":1},{"id":1}]`},
+		{"application/json", []*fieldsetOrder{&order, nil}, []string{"id"}, `[{"id":1},null]`},
+		{"application/json", map[string]int{"a": 1, "b": 2}, []string{"b"}, `{"b":2}`},
+		{"application/json", PageEnvelope{Data: []fieldsetOrder{order}}, []string{"email"}, `"data":[{"email":"a@example.com"}]`},
+		{"application/json", order, []string{}, `{}`},
+		{"application/xml", order, []string{"id", "total"}, `<fieldsetOrder total="9.5"><ID>1</ID></fieldsetOrder>`},
+		{"text/csv", []fieldsetOrder{order}, []string{"email", "id"}, "id,email\n1,a@example.com\n"},
+		{"application/vnd.api+json", order, []string{"email"}, `"type":"fieldsetorder"`},
+		{"application/hal+json", []fieldsetOrder{order}, []string{"email"}, `"_embedded":{"fieldsetorder":[`},
+	}
+
+	for _, tt := range tests {
+		got := respondFields(t, tt.accept, tt.data, tt.fields...)
+		switch {
+		case strings.HasSuffix(tt.accept, "json"):
+			var b bytes.Buffer
+			if err := json.Compact(&b, []byte(got)); err != nil {
+				t.Fatalf("%s %v: %v: %s", tt.accept, tt.fields, err, got)
+			}
+			got = b.String()
+		case strings.HasSuffix(tt.accept, "xml"):
+			got = regexp.MustCompile(`>\s+<`).ReplaceAllString(got, "><")
+		}
+		if !strings.Contains(got, tt.want) {
+			t.Errorf("%s %v: got %s, want %s", tt.accept, tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestWithFieldsUnknownNames(t *testing.T) {
+	order := fieldsetOrder{ID: 1}
+	respondFields(t, "application/json", order, "id")
+	before := prunedCount.Load()
+	for _, name := range []string{"a", "b", "c", "d"} {
+		respondFields(t, "application/json", order, "id", name)
+	}
+	if after := prunedCount.Load(); after != before {
+		t.Errorf("unknown field names built %d new types", after-before)
+	}
+}
+
+func TestWithFieldsSparse(t *testing.T) {
+	saved := prunedCount.Load()
+	prunedCount.Store(maxPrunedTypes)
+	defer prunedCount.Store(saved)
+
+	type sparseOrder fieldsetOrder
+	order := sparseOrder{ID: 1, Email: "a@example.com", Tags: []string{}}
+	tests := []struct {
+		fields []string
+		want   string
+	}{
+		{[]string{"id", "tags"}, `{"id":1,"tags":[]}`},
+		{[]string{"email", "notes"}, `{"email":"a@example.com"}`},
+		{[]string{"total"}, `{"total":0}`},
+	}
+
+	for _, tt := range tests {
+		got := respondFields(t, "application/json", order, tt.fields...)
+		var doc, want map[string]interface{}
+		if err := json.Unmarshal([]byte(got), &doc); err != nil {
+			t.Fatalf("%v: %v: %s", tt.fields, err, got)
+		}
+		json.Unmarshal([]byte(tt.want), &want)
+		if len(doc) != len(want) {
+			t.Errorf("%v: got %s, want %s", tt.fields, got, tt.want)
+		}
+		for key := range want {
+			if _, ok := doc[key]; !ok {
+				t.Errorf("%v: got %s, want %s", tt.fields, got, tt.want)
+			}
+		}
+	}
+	// The sparse type is cached, so a repeated run builds none.
+	if got := prunedCount.Load(); got > maxPrunedTypes+1 {
+		t.Errorf("sparse pruning built %d types, want at most 1", got-maxPrunedTypes)
+	}
+}
@@ -0,0 +1,272 @@
+package router
+
+import (
+	"fmt"
+	"mime/multipart"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// formNode is one level of form keys. The key items[0].name is stored as
+// the path items → 0 → name, so dotted and bracketed spellings meet in the
+// same node.
+type formNode struct {
+	values   []string
+	files    []*multipart.FileHeader
+	children map[string]*formNode
+}
+
+// newFormTree builds the tree of the given form values.
+func newFormTree(values map[string][]string) *formNode {
+	root := &formNode{}
+	for key, list := range values {
+		node := root.at(key)
+		node.values = append(node.values, list...)
+	}
+	return root
+}
+
+// addFiles stores the files uploaded under key.
+func (n *formNode) addFiles(key string, files []*multipart.FileHeader) {
+	node := n.at(key)
+	node.files = append(node.files, files...)
+}
+
+// at returns the node of key, creating it and its parents as needed.
+func (n *formNode) at(key string) *formNode {
+	node := n
+	for _, segment := range formKeySegments(key) {
+		node = node.child(segment)
+	}
+	return node
+}
+
+func (n *formNode) child(segment string) *formNode {
+	if n.children == nil {
+		n.children = map[string]*formNode{}
+	}
+	c, ok := n.children[segment]
+	if !ok {
+		c = &formNode{}
+		n.children[segment] = c
+	}
+	return c
+}
+
+// indexed returns the children with numeric names in index order. Gaps are
+// closed up, so items[0] and items[5] become two elements. Indexes must be
+// written plainly: items[01], items[+1] and items[-1] are rejected rather
+// than guessed at.
+func (n *formNode) indexed() ([]*formNode, error) {
+	type element struct {
+		index int
+		node  *formNode
+	}
+	var elements []element
+	for segment, c := range n.children {
+		i, err := strconv.Atoi(segment)
+		if err != nil {
+			continue
+		}
+		if i < 0 || strconv.Itoa(i) != segment {
+			return nil, fmt.Errorf("invalid index %q", segment)
+		}
+		elements = append(elements, element{i, c})
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		return elements[i].index < elements[j].index
+	})
+	nodes := make([]*formNode, len(elements))
+	for i, e := range elements {
+		nodes[i] = e.node
+	}
+	return nodes, nil
+}
+
+// formKeySegments splits a key such as items[0].name or address[city] into
+// its path. Malformed keys are kept whole.
+func formKeySegments(key string) []string {
+	end := strings.IndexAny(key, "[.")
+	if end <= 0 {
+		return []string{key}
+	}
+	segments := []string{key[:end]}
+	rest := key[end:]
+	for rest != "" {
+		switch rest[0] {
+		case '[':
+			closing := strings.IndexByte(rest, ']')
+			if closing < 0 {
+				return []string{key}
+			}
+			segments = append(segments, rest[1:closing])
+			rest = rest[closing+1:]
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, "[.")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return []string{key}
+			}
+			segments = append(segments, rest[:end])
+			rest = rest[end:]
+		default:
+			return []string{key}
+		}
+	}
+	return segments
+}
+
+// bindFormStruct sets the fields of struct v from node. A field is named by
+// its form tag, or by its Go name when it has none; form:"-" skips it.
+// Untagged embedded structs share their parent's keys.
+func bindFormStruct(node *formNode, v reflect.Value, key, path string, errs *BindErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+		name, tagged := sf.Tag.Lookup("form")
+		if name == "-" {
+			continue
+		}
+		fieldPath := sf.Name
+		if path != "" {
+			fieldPath = path + "." + sf.Name
+		}
+
+		if sf.Anonymous && !tagged && !isFormLeaf(sf.Type) {
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if !sf.IsExported() && sf.Type.Kind() == reflect.Pointer {
+					continue
+				}
+				bindFormValue(node, v.Field(i), key, fieldPath, errs)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+		child, ok := node.children[name]
+		if !ok {
+			continue
+		}
+		fieldKey := name
+		if key != "" {
+			fieldKey = key + "." + name
+		}
+		bindFormValue(child, v.Field(i), fieldKey, fieldPath, errs)
+	}
+}
+
+// bindFormValue sets v from node, allocating pointers only when there is
+// something to store.
+func bindFormValue(node *formNode, v reflect.Value, key, path string, errs *BindErrors) {
+	if bindFormFiles(node, v, key, path, errs) {
+		return
+	}
+	t := v.Type()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case isFormLeaf(t):
+		// Empty values leave the field alone, as an empty input does.
+		if len(node.values) == 0 || node.values[0] == "" {
+			return
+		}
+		if err := setString(v, node.values[0]); err != nil {
+			*errs = append(*errs, &BindError{Source: "form", Name: key, Field: path, Err: err})
+		}
+
+	case t.Kind() == reflect.Slice && isFormLeaf(t.Elem()):
+		// tags=a&tags=b, tags[]=a&tags[]=b and tags[0]=a&tags[1]=b all
+		// fill the slice in order.
+		values := node.values
+		if c, ok := node.children[""]; ok {
+			values = append(values, c.values...)
+		}
+		elements, err := node.indexed()
+		if err != nil {
+			*errs = append(*errs, &BindError{Source: "form", Name: key, Field: path, Err: err})
+			return
+		}
+		for _, c := range elements {
+			values = append(values, c.values...)
+		}
+		if err := setStrings(v, values); err != nil {
+			*errs = append(*errs, &BindError{Source: "form", Name: key, Field: path, Err: err})
+		}
+
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Slice:
+		elements, err := node.indexed()
+		if err != nil {
+			*errs = append(*errs, &BindError{Source: "form", Name: key, Field: path, Err: err})
+			return
+		}
+		if len(elements) == 0 {
+			return
+		}
+		v = allocated(v)
+		list := reflect.MakeSlice(t, len(elements), len(elements))
+		for i, c := range elements {
+			index := "[" + strconv.Itoa(i) + "]"
+			bindFormValue(c, list.Index(i), key+index, path+index, errs)
+		}
+		v.Set(list)
+
+	case t.Kind() == reflect.Struct:
+		if len(node.children) == 0 {
+			return
+		}
+		bindFormStruct(node, allocated(v), key, path, errs)
+
+	default:
+		if len(node.values) > 0 || len(node.children) > 0 {
+			*errs = append(*errs, &BindError{Source: "form", Name: key, Field: path, Err: fmt.Errorf("unsupported type %s", t)})
+		}
+	}
+}
+
+// allocated follows v through pointers, allocating nil ones.
+func allocated(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// isFormLeaf reports whether setString can parse a value of type t.
+func isFormLeaf(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
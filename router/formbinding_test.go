@@ -0,0 +1,121 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formItem struct {
+	Name string `form:"name"`
+	Qty  int    `form:"qty"`
+}
+
+type formAddress struct {
+	City string `form:"city"`
+}
+
+type formOrder struct {
+	Email string       `form:"email"`
+	Due   time.Time    `form:"due"`
+	Tags  []string     `form:"tags"`
+	Items []formItem   `form:"items"`
+	Ship  *formAddress `form:"shipping"`
+	Skip  string       `form:"-"`
+}
+
+func formRequest(body string) *http.Request {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestBindForm(t *testing.T) {
+	tests := []struct {
+		body string
+		want formOrder
+	}{
+		{"email=a%40example.com", formOrder{Email: "a@example.com"}},
+		{"due=2024-05-01T00:00:00Z", formOrder{Due: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}},
+		{"tags=a&tags=b", formOrder{Tags: []string{"a", "b"}}},
+		{"tags[]=a&tags[]=b", formOrder{Tags: []string{"a", "b"}}},
+		{"tags[1]=b&tags[0]=a", formOrder{Tags: []string{"a", "b"}}},
+		{"items[0].name=x&items[0][qty]=2&items[5].name=y", formOrder{Items: []formItem{{"x", 2}, {"y", 0}}}},
+		{"shipping.city=Oslo", formOrder{Ship: &formAddress{City: "Oslo"}}},
+		{"shipping[city]=Oslo", formOrder{Ship: &formAddress{City: "Oslo"}}},
+		{"email=&Skip=x&unknown=1", formOrder{}},
+	}
+
+	for _, tt := range tests {
+		var got formOrder
+		if err := BindForm(formRequest(tt.body), &got); err != nil {
+			t.Errorf("%s: %v", tt.body, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: bound %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestBindFormErrors(t *testing.T) {
+	tests := []struct {
+		body  string
+		names []string
+	}{
+		{"items[0].qty=x&due=soon", []string{"due", "items[0].qty"}},
+		// Indexes that are not written plainly are rejected, not looked up.
+		{"tags[01]=a", []string{"tags"}},
+		{"items[%2B1].name=x", []string{"items"}},
+		{"items[-1].name=x", []string{"items"}},
+		{"tags[1]=a&tags[01]=b", []string{"tags"}},
+	}
+
+	for _, tt := range tests {
+		var v formOrder
+		err := BindForm(formRequest(tt.body), &v)
+		var errs BindErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: error = %v, want BindErrors", tt.body, err)
+			continue
+		}
+		if errs.StatusCode() != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.body, errs.StatusCode())
+		}
+		var names []string
+		for _, e := range errs {
+			names = append(names, e.Name)
+		}
+		sort.Strings(names)
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("%s: errors for %v, want %v", tt.body, names, tt.names)
+		}
+	}
+}
+
+func TestFormKeySegments(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"name", []string{"name"}},
+		{"items[0].name", []string{"items", "0", "name"}},
+		{"items[0][name]", []string{"items", "0", "name"}},
+		{"address.city", []string{"address", "city"}},
+		{"tags[]", []string{"tags", ""}},
+		{"broken[0", []string{"broken[0"}},
+		{"a..b", []string{"a..b"}},
+		{"[0]", []string{"[0]"}},
+	}
+
+	for _, tt := range tests {
+		if got := formKeySegments(tt.key); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("formKeySegments(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// HAL documents (https://datatracker.ietf.org/doc/html/draft-kelly-json-hal)
+// for application/hal+json.
+
+type halLink struct {
+	Href string `json:"href"`
+}
+
+// NewHALEncoder returns an encoder producing HAL documents from structs
+// tagged as for NewJSONAPIEncoder. A resource's identifier and attributes
+// become properties, its links go under _links and related resources are
+// embedded under _embedded. The "related" link of a relationship is added to
+// _links under the relationship's name. A slice becomes a
+// collection embedding its items under their resource type; a PageEnvelope
+// adds its pagination links and meta.
+//
+// As with NewJSONAPIEncoder, links need a router; the encoder registered for
+// application/hal+json by default has none.
+func NewHALEncoder(routes *Router) Encoder {
+	return EncoderFunc(func(w io.Writer, v interface{}) error {
+		data, links, meta := hypermediaDocument(v)
+		b := &halBuilder{routes: routes, visiting: map[jsonAPIIdentifier]bool{}}
+
+		var doc interface{}
+		var err error
+		rv := indirectValue(reflect.ValueOf(data))
+		if rv.IsValid() && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) || links != nil {
+			doc, err = b.collection(rv, links, meta)
+		} else if rv.IsValid() {
+			// A nil resource leaves doc nil, which encodes as null.
+			doc, err = b.resource(rv)
+		}
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	})
+}
+
+// halBuilder renders the resources of one document. visiting holds the
+// resources being embedded, so that a cycle of related resources stops
+// embedding rather than recursing forever; depth catches cycles of
+// resources without identifiers.
+type halBuilder struct {
+	routes   *Router
+	visiting map[jsonAPIIdentifier]bool
+	depth    int
+}
+
+func (b *halBuilder) collection(v reflect.Value, links *PageLinks, meta *PageMeta) (jsonObject, error) {
+	var doc jsonObject
+	if links != nil {
+		var halLinks jsonObject
+		for _, link := range []struct{ rel, href string }{
+			{"first", links.First},
+			{"prev", links.Prev},
+			{"next", links.Next},
+			{"last", links.Last},
+		} {
+			if link.href != "" {
+				halLinks = append(halLinks, jsonMember{link.rel, halLink{link.href}})
+			}
+		}
+		if len(halLinks) > 0 {
+			doc = append(doc, jsonMember{"_links", halLinks})
+		}
+	}
+	if meta != nil {
+		doc = append(doc, resourceInfoFor(reflect.TypeOf(*meta)).attributes(reflect.ValueOf(*meta))...)
+	}
+
+	if v.IsValid() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		name := "items"
+		if elem := v.Type().Elem(); elem.Kind() == reflect.Struct || elem.Kind() == reflect.Pointer && elem.Elem().Kind() == reflect.Struct {
+			if elem.Kind() == reflect.Pointer {
+				elem = elem.Elem()
+			}
+			name = resourceInfoFor(elem).typ
+		}
+		items, err := b.resources(v)
+		if err != nil {
+			return nil, err
+		}
+		doc = append(doc, jsonMember{"_embedded", jsonObject{{name, items}}})
+	} else if v.IsValid() {
+		item, err := b.resource(v)
+		if err != nil {
+			return nil, err
+		}
+		doc = append(doc, jsonMember{"_embedded", jsonObject{{"item", item}}})
+	}
+	return doc, nil
+}
+
+func (b *halBuilder) resources(v reflect.Value) ([]jsonObject, error) {
+	items := make([]jsonObject, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item, err := b.resource(v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		if item != nil {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// resource renders v as a HAL resource, or returns nil when v is nil.
+func (b *halBuilder) resource(v reflect.Value) (jsonObject, error) {
+	rv, ok := resourceStruct(v)
+	if !ok {
+		if indirectValue(v).IsValid() {
+			return nil, fmt.Errorf("hal: cannot encode %s as a resource", v.Type())
+		}
+		return nil, nil
+	}
+	v = rv
+	if b.depth++; b.depth > maxBinaryDepth {
+		return nil, fmt.Errorf("hal: resources nested too deeply")
+	}
+	defer func() { b.depth-- }()
+
+	info := resourceInfoFor(v.Type())
+	id, err := info.resourceID(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var links jsonObject
+	hrefs, err := info.resolveLinks(b.routes, v, info.links)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range info.links {
+		if href, ok := hrefs[link.rel]; ok {
+			links = append(links, jsonMember{link.rel, halLink{href}})
+		}
+	}
+
+	var doc jsonObject
+	if info.id != nil {
+		if fv, ok := fieldByIndex(v, info.id.index); ok {
+			doc = append(doc, jsonMember{info.id.name, fv.Interface()})
+		}
+	}
+	doc = append(doc, info.attributes(v)...)
+
+	// A resource already being embedded further up is not embedded again.
+	key := jsonAPIIdentifier{Type: info.typ, ID: id}
+	cycle := id != "" && b.visiting[key]
+	if id != "" && !cycle {
+		b.visiting[key] = true
+		defer delete(b.visiting, key)
+	}
+
+	var embedded jsonObject
+	for _, rel := range info.relations {
+		relHrefs, err := info.resolveLinks(b.routes, v, rel.links)
+		if err != nil {
+			return nil, err
+		}
+		if href, ok := relHrefs["related"]; ok {
+			links = append(links, jsonMember{rel.field.name, halLink{href}})
+		}
+		if cycle {
+			continue
+		}
+
+		fv, _ := fieldByIndex(v, rel.field.index)
+		if fv = indirectValue(fv); !fv.IsValid() {
+			continue
+		}
+		var related interface{}
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			related, err = b.resources(fv)
+		} else {
+			related, err = b.resource(fv)
+		}
+		if err != nil {
+			return nil, err
+		}
+		embedded = append(embedded, jsonMember{rel.field.name, related})
+	}
+
+	if len(links) > 0 {
+		doc = append(jsonObject{{"_links", links}}, doc...)
+	}
+	if len(embedded) > 0 {
+		doc = append(doc, jsonMember{"_embedded", embedded})
+	}
+	return doc, nil
+}
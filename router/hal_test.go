@@ -0,0 +1,100 @@
+package router
+
+import (
+	"bytes"
+	"testing"
+)
+
+// halNode is a resource without an identifier that can contain itself.
+type halNode struct {
+	Name  string   `json:"name"`
+	Child *halNode `json:"child" resource:"relation"`
+}
+
+// halFriend is a resource whose relations can form a cycle.
+type halFriend struct {
+	ID      int          `json:"id" resource:"id,friends"`
+	Friends []*halFriend `json:"friends" resource:"relation"`
+}
+
+func TestHALEncoder(t *testing.T) {
+	articles := hmArticles()
+	total := 2
+	tests := []struct {
+		name   string
+		routes *Router
+		v      interface{}
+		want   string
+	}{
+		{
+			"single resource with links",
+			hmRouter(),
+			articles[0],
+			`{
+				"_links": {"self": {"href": "/articles/1"}, "author": {"href": "/articles/1/author"}},
+				"id": 1,
+				"title": "Hi",
+				"_embedded": {
+					"author": {"_links": {"self": {"href": "/people/9"}}, "id": 9, "name": "Ann"},
+					"comments": [{
+						"id": "c1", "body": "Nice",
+						"_embedded": {"author": {"_links": {"self": {"href": "/people/9"}}, "id": 9, "name": "Ann"}}
+					}]
+				}
+			}`,
+		},
+		{
+			"collection",
+			nil,
+			articles[1:],
+			`{"_embedded": {"articles": [{"id": 2, "title": "Draft", "draft": true, "_embedded": {"comments": []}}]}}`,
+		},
+		{
+			"page",
+			nil,
+			&PageEnvelope{
+				Data:  []*hmPerson{{ID: 9, Name: "Ann"}, nil},
+				Meta:  PageMeta{Page: 2, PerPage: 1, Total: &total},
+				Links: PageLinks{First: "/people?page=1", Prev: "/people?page=1"},
+			},
+			`{
+				"_links": {"first": {"href": "/people?page=1"}, "prev": {"href": "/people?page=1"}},
+				"page": 2, "per_page": 1, "total": 2,
+				"_embedded": {"people": [{"id": 9, "name": "Ann"}]}
+			}`,
+		},
+		{"nil", nil, (*hmArticle)(nil), `null`},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := NewHALEncoder(tt.routes).Encode(&buf, tt.v); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !jsonEqual(t, buf.Bytes(), []byte(tt.want)) {
+			t.Errorf("%s: got\n%s", tt.name, buf.String())
+		}
+	}
+}
+
+func TestHALEncoderCycles(t *testing.T) {
+	a := &halFriend{ID: 1}
+	b := &halFriend{ID: 2, Friends: []*halFriend{a}}
+	a.Friends = []*halFriend{b}
+	var buf bytes.Buffer
+	if err := NewHALEncoder(nil).Encode(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"id": 1, "_embedded": {"friends": [{"id": 2, "_embedded": {"friends": [{"id": 1}]}}]}}`
+	if !jsonEqual(t, buf.Bytes(), []byte(want)) {
+		t.Errorf("got\n%s", buf.String())
+	}
+
+	// Without identifiers a cycle is only caught by the depth limit.
+	n := &halNode{Name: "loop"}
+	n.Child = n
+	if err := NewHALEncoder(nil).Encode(&bytes.Buffer{}, n); err == nil {
+		t.Error("encoding a cycle of anonymous resources succeeded")
+	}
+}
@@ -0,0 +1,210 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// resourceInfo describes how a struct type is rendered as a resource by the
+// hypermedia encoders, as set out by its resource and links tags.
+type resourceInfo struct {
+	typ       string
+	id        *structField
+	attrs     []structField
+	relations []resourceRelation
+	links     []resourceLink
+}
+
+type resourceRelation struct {
+	field structField
+	links []resourceLink
+}
+
+// resourceLink is one rel=route pair of a links tag.
+type resourceLink struct {
+	rel   string
+	route string
+}
+
+var resourceCache sync.Map // reflect.Type -> *resourceInfo
+
+func resourceInfoFor(t reflect.Type) *resourceInfo {
+	if cached, ok := resourceCache.Load(t); ok {
+		return cached.(*resourceInfo)
+	}
+
+	info := &resourceInfo{typ: strings.ToLower(t.Name())}
+	if origin := prunedOrigin(t); origin != t {
+		// Types built by WithFields have no name; use the original's.
+		info.typ = resourceInfoFor(origin).typ
+	}
+	for _, f := range structFields(t, "json") {
+		sf := fieldType(t, f.index)
+		kind, resourceType, _ := strings.Cut(sf.Tag.Get("resource"), ",")
+		links := parseResourceLinks(sf.Tag.Get("links"))
+		switch kind {
+		case "id":
+			f := f
+			info.id = &f
+			if resourceType != "" {
+				info.typ = resourceType
+			}
+			info.links = links
+		case "relation":
+			info.relations = append(info.relations, resourceRelation{field: f, links: links})
+		default:
+			info.attrs = append(info.attrs, f)
+		}
+	}
+
+	resourceCache.Store(t, info)
+	return info
+}
+
+func parseResourceLinks(tag string) []resourceLink {
+	var links []resourceLink
+	for _, part := range strings.Split(tag, ",") {
+		rel, route, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok && rel != "" && route != "" {
+			links = append(links, resourceLink{rel: rel, route: route})
+		}
+	}
+	return links
+}
+
+// resourceStruct returns the struct behind v, following pointers, or false
+// when v is nil or not a struct.
+func resourceStruct(v reflect.Value) (reflect.Value, bool) {
+	v = indirectValue(v)
+	if !v.IsValid() || v.Kind() != reflect.Struct || v.Type() == timeType {
+		return reflect.Value{}, false
+	}
+	return v, true
+}
+
+// resourceID formats the identifier of resource v, or returns "".
+func (info *resourceInfo) resourceID(v reflect.Value) (string, error) {
+	if info.id == nil {
+		return "", nil
+	}
+	fv, ok := fieldByIndex(v, info.id.index)
+	if !ok {
+		return "", nil
+	}
+	return csvCell(fv)
+}
+
+// attributes lists the attributes of resource v in declaration order,
+// honoring omitempty.
+func (info *resourceInfo) attributes(v reflect.Value) jsonObject {
+	var attrs jsonObject
+	for _, f := range info.attrs {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+		attrs = append(attrs, jsonMember{f.name, fv.Interface()})
+	}
+	return attrs
+}
+
+// resolveLinks builds the hrefs of links for resource v. Without a router
+// no links are produced.
+func (info *resourceInfo) resolveLinks(routes *Router, v reflect.Value, links []resourceLink) (map[string]string, error) {
+	if routes == nil || len(links) == 0 {
+		return nil, nil
+	}
+
+	var params []string
+	id, err := info.resourceID(v)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" {
+		params = append(params, "id", id)
+	}
+	for _, f := range info.attrs {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if fv = indirectValue(fv); !isScalarValue(fv) {
+			continue
+		}
+		if s, err := csvCell(fv); err == nil {
+			params = append(params, f.name, s)
+		}
+	}
+
+	hrefs := make(map[string]string, len(links))
+	for _, link := range links {
+		href, err := routes.URL(link.route, params...)
+		if err != nil {
+			return nil, err
+		}
+		hrefs[link.rel] = href
+	}
+	return hrefs, nil
+}
+
+// isScalarValue reports whether v reads naturally as a route parameter.
+func isScalarValue(v reflect.Value) bool {
+	if !v.IsValid() {
+		return false
+	}
+	if isCSVLeaf(v.Type()) {
+		return true
+	}
+	switch v.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// hypermediaDocument splits what is handed to a hypermedia encoder into the
+// primary data and, for a PageEnvelope, its pagination links and metadata.
+func hypermediaDocument(v interface{}) (data interface{}, links *PageLinks, meta *PageMeta) {
+	switch env := v.(type) {
+	case PageEnvelope:
+		return env.Data, &env.Links, &env.Meta
+	case *PageEnvelope:
+		return env.Data, &env.Links, &env.Meta
+	}
+	return v, nil, nil
+}
+
+// jsonObject is a JSON object that keeps its members in order.
+type jsonObject []jsonMember
+
+type jsonMember struct {
+	key   string
+	value interface{}
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", m.key, err)
+		}
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"testing"
+)
+
+type hmPerson struct {
+	ID   int    `json:"id" resource:"id,people" links:"self=person"`
+	Name string `json:"name"`
+}
+
+type hmComment struct {
+	ID     string    `json:"id" resource:"id"`
+	Body   string    `json:"body"`
+	Author *hmPerson `json:"author" resource:"relation"`
+}
+
+type hmArticle struct {
+	ID       int         `json:"id" resource:"id,articles" links:"self=article"`
+	Title    string      `json:"title"`
+	Draft    bool        `json:"draft,omitempty"`
+	Author   *hmPerson   `json:"author" resource:"relation" links:"related=article-author"`
+	Comments []hmComment `json:"comments" resource:"relation"`
+}
+
+func hmRouter() *Router {
+	r := NewRouter()
+	r.Handle("GET", "/people/{id}", nil).Name("person")
+	r.Handle("GET", "/articles/{id}", nil).Name("article")
+	r.Handle("GET", "/articles/{id}/author", nil).Name("article-author")
+	return r
+}
+
+func hmArticles() []hmArticle {
+	ann := &hmPerson{ID: 9, Name: "Ann"}
+	return []hmArticle{
+		{ID: 1, Title: "Hi", Author: ann, Comments: []hmComment{{ID: "c1", Body: "Nice", Author: ann}}},
+		{ID: 2, Title: "Draft", Draft: true, Comments: []hmComment{}},
+	}
+}
+
+func TestRouterURL(t *testing.T) {
+	r := hmRouter()
+	r.Handle("GET", "/files/{name}/v{version:int}", nil).Name("file")
+
+	tests := []struct {
+		name   string
+		params []string
+		want   string
+		err    bool
+	}{
+		{"article", []string{"id", "42"}, "/articles/42", false},
+		{"article", []string{"id", "42", "extra", "x"}, "/articles/42", false},
+		{"file", []string{"name", "report", "version", "3"}, "/files/report/v3", false},
+		{"file", []string{"name", "report", "version", "x"}, "", true},
+		{"article", []string{"id"}, "", true},
+		{"article", nil, "", true},
+		{"missing", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := r.URL(tt.name, tt.params...)
+		if got != tt.want || (err != nil) != tt.err {
+			t.Errorf("URL(%s, %v) = %q, %v", tt.name, tt.params, got, err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate route name did not panic")
+		}
+	}()
+	r.Handle("GET", "/other", nil).Name("article")
+}
@@ -0,0 +1,230 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/locales"
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	entranslations "github.com/go-playground/validator/v10/translations/en"
+)
+
+// Translators for localized messages. English is registered by default and
+// is the fallback for every other language.
+var (
+	translatorsMu sync.RWMutex
+	translators   = ut.New(en.New())
+	// languages lists the registered languages as BCP 47 tags, in order of
+	// registration, for negotiation.
+	languages []string
+)
+
+func init() {
+	if err := RegisterTranslator(en.New(), entranslations.RegisterDefaultTranslations); err != nil {
+		panic(err)
+	}
+}
+
+// RegisterTranslator adds a language for localized messages. register, if
+// not nil, installs the language's validation messages on the validator used
+// by ValidateStruct; the packages under
+// github.com/go-playground/validator/v10/translations provide one per
+// language:
+//
+//	router.RegisterTranslator(fr.New(), frtranslations.RegisterDefaultTranslations)
+func RegisterTranslator(locale locales.Translator, register func(*validator.Validate, ut.Translator) error) error {
+	translatorsMu.Lock()
+	defer translatorsMu.Unlock()
+
+	if err := translators.AddTranslator(locale, true); err != nil {
+		return err
+	}
+	trans, _ := translators.GetTranslator(locale.Locale())
+	if register != nil {
+		if err := register(validate, trans); err != nil {
+			return err
+		}
+	}
+
+	tag := localeTag(locale.Locale())
+	for _, existing := range languages {
+		if existing == tag {
+			return nil
+		}
+	}
+	languages = append(languages, tag)
+	return nil
+}
+
+// AddTranslation adds or replaces the text for key in a registered language.
+// Text may contain the placeholders {0}, {1}, ... filled by T.
+func AddTranslation(language, key, text string) error {
+	translatorsMu.Lock()
+	defer translatorsMu.Unlock()
+
+	trans, found := translators.GetTranslator(tagLocale(language))
+	if !found {
+		return errors.New("i18n: no translator registered for " + language)
+	}
+	return trans.Add(key, text, true)
+}
+
+// localeTag turns a locale name such as pt_BR into the BCP 47 tag pt-BR.
+func localeTag(locale string) string {
+	return strings.ReplaceAll(locale, "_", "-")
+}
+
+func tagLocale(tag string) string {
+	return strings.ReplaceAll(tag, "-", "_")
+}
+
+// LanguageOptions configures LanguageMiddleware.
+type LanguageOptions struct {
+	// Supported lists the languages offered to clients as BCP 47 tags, most
+	// preferred first; the first is used when nothing else matches.
+	// Defaults to the languages registered with RegisterTranslator.
+	Supported []string
+}
+
+// LanguageMiddleware negotiates the language of each response from the
+// Accept-Language header and stores it in the request context, where
+// Language, Translator and T read it. It adds Accept-Language to Vary.
+func LanguageMiddleware(opts LanguageOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			supported := opts.Supported
+			if len(supported) == 0 {
+				supported = registeredLanguages()
+			}
+
+			addVary(w.Header(), "Accept-Language")
+			language := NegotiateLanguage(r, supported)
+			ctx := context.WithValue(r.Context(), languageKey, language)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// NegotiateLanguage returns the offer that best matches the request's
+// Accept-Language header, following RFC 4647. A range matches an offer
+// that equals it or starts with it, as "en" matches "en-GB"; failing that,
+// the range is shortened, so "de-CH" matches "de". The first offer is
+// returned when nothing matches.
+func NegotiateLanguage(r *http.Request, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	specs := parseAcceptHeader(r.Header.Get("Accept-Language"))
+	sort.SliceStable(specs, func(i, j int) bool { return specs[i].q > specs[j].q })
+
+	for _, spec := range specs {
+		if spec.q <= 0 {
+			break
+		}
+		if spec.value == "*" {
+			return offers[0]
+		}
+		if offer := matchLanguage(spec.value, offers); offer != "" {
+			return offer
+		}
+	}
+	return offers[0]
+}
+
+func matchLanguage(tag string, offers []string) string {
+	for _, offer := range offers {
+		if strings.EqualFold(offer, tag) {
+			return offer
+		}
+	}
+	for _, offer := range offers {
+		if len(offer) > len(tag) && strings.EqualFold(offer[:len(tag)], tag) && offer[len(tag)] == '-' {
+			return offer
+		}
+	}
+	for i := strings.LastIndexByte(tag, '-'); i > 0; i = strings.LastIndexByte(tag, '-') {
+		tag = tag[:i]
+		for _, offer := range offers {
+			if strings.EqualFold(offer, tag) {
+				return offer
+			}
+		}
+	}
+	return ""
+}
+
+// Language returns the language chosen for r by LanguageMiddleware or,
+// without the middleware, negotiates it against the registered languages.
+func Language(r *http.Request) string {
+	if language, ok := r.Context().Value(languageKey).(string); ok {
+		return language
+	}
+	return NegotiateLanguage(r, registeredLanguages())
+}
+
+// Translator returns the translator for the language of r, falling back to
+// English when the language has no registered translator.
+func Translator(r *http.Request) ut.Translator {
+	language := Language(r)
+	translatorsMu.RLock()
+	defer translatorsMu.RUnlock()
+	trans, _ := translators.FindTranslator(tagLocale(language), primaryLanguage(language))
+	return trans
+}
+
+// T translates key into the language of r, substituting params for {0},
+// {1}, ... Keys without a translation in that language or in English are
+// returned as they are, so English text can be used as the key.
+func T(r *http.Request, key string, params ...string) string {
+	trans := Translator(r)
+	translatorsMu.RLock()
+	fallback := translators.GetFallback()
+	translatorsMu.RUnlock()
+
+	for _, t := range []ut.Translator{trans, fallback} {
+		if text, ok := translate(t, key, params); ok {
+			return text
+		}
+	}
+	return key
+}
+
+// translate calls trans.T, which panics when a text has more placeholders
+// than there are params.
+func translate(trans ut.Translator, key string, params []string) (text string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			text, ok = "", false
+		}
+	}()
+	text, err := trans.T(key, params...)
+	return text, err == nil
+}
+
+// ValidationMessages translates the errors returned by ValidateStruct into
+// the language of r, keyed by the namespace of each failing field. It
+// returns nil for errors that did not come from validation.
+func ValidationMessages(r *http.Request, err error) map[string]string {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+	return errs.Translate(Translator(r))
+}
+
+func registeredLanguages() []string {
+	translatorsMu.RLock()
+	defer translatorsMu.RUnlock()
+	return append([]string(nil), languages...)
+}
+
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(tag, "-")
+	return primary
+}
@@ -0,0 +1,145 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/locales/fr"
+	frtranslations "github.com/go-playground/validator/v10/translations/fr"
+)
+
+var registerFrench sync.Once
+
+// withFrench registers French, with two application messages, for tests
+// that need a second language.
+func withFrench(t *testing.T) {
+	t.Helper()
+	registerFrench.Do(func() {
+		if err := RegisterTranslator(fr.New(), frtranslations.RegisterDefaultTranslations); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddTranslation("fr", "Hello {0}", "Bonjour {0}"); err != nil {
+			t.Fatal(err)
+		}
+		if err := AddTranslation("fr", "Not Found", "Introuvable"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func languageRequest(acceptLanguage string) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	if acceptLanguage != "" {
+		r.Header.Set("Accept-Language", acceptLanguage)
+	}
+	return r
+}
+
+func TestNegotiateLanguage(t *testing.T) {
+	offers := []string{"en", "en-GB", "fr", "pt-BR"}
+	tests := []struct {
+		header string
+		offers []string
+		want   string
+	}{
+		{"", offers, "en"},
+		{"fr", offers, "fr"},
+		{"FR-ca", offers, "fr"},
+		{"en-GB", offers, "en-GB"},
+		{"pt", offers, "pt-BR"},
+		{"de, fr;q=0.5", offers, "fr"},
+		{"fr;q=0.3, en-GB;q=0.8", offers, "en-GB"},
+		{"de-CH-1996, *;q=0.5", offers, "en"},
+		{"de, fr;q=0", offers, "en"},
+		{"zh-Hant-TW", offers, "en"},
+		{"fr", nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := NegotiateLanguage(languageRequest(tt.header), tt.offers); got != tt.want {
+			t.Errorf("Accept-Language %q: got %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestLanguageMiddleware(t *testing.T) {
+	withFrench(t)
+	tests := []struct {
+		opts   LanguageOptions
+		header string
+		want   string
+	}{
+		{LanguageOptions{}, "fr-CA, en;q=0.5", "fr"},
+		{LanguageOptions{}, "it", "en"},
+		{LanguageOptions{Supported: []string{"de", "fr"}}, "it", "de"},
+		{LanguageOptions{Supported: []string{"de", "fr"}}, "en, fr;q=0.1", "fr"},
+	}
+
+	for _, tt := range tests {
+		h := LanguageMiddleware(tt.opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, Language(r))
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, languageRequest(tt.header))
+		if w.Body.String() != tt.want || w.Header().Get("Vary") != "Accept-Language" {
+			t.Errorf("%+v, %q: language %q, Vary %q", tt.opts, tt.header, w.Body, w.Header().Get("Vary"))
+		}
+	}
+
+	// Without the middleware the registered languages are negotiated.
+	if got := Language(languageRequest("fr")); got != "fr" {
+		t.Errorf("Language without the middleware = %q", got)
+	}
+}
+
+func TestT(t *testing.T) {
+	withFrench(t)
+	tests := []struct {
+		header string
+		key    string
+		params []string
+		want   string
+	}{
+		{"fr", "Hello {0}", []string{"Ann"}, "Bonjour Ann"},
+		{"fr-CA", "Hello {0}", []string{"Ann"}, "Bonjour Ann"},
+		// English has no translation for the key, which is returned as is.
+		{"en", "Hello {0}", []string{"Ann"}, "Hello {0}"},
+		{"fr", "Goodbye", nil, "Goodbye"},
+		// Too few params for the placeholders.
+		{"fr", "Hello {0}", nil, "Hello {0}"},
+	}
+
+	for _, tt := range tests {
+		if got := T(languageRequest(tt.header), tt.key, tt.params...); got != tt.want {
+			t.Errorf("%s: T(%q) = %q, want %q", tt.header, tt.key, got, tt.want)
+		}
+	}
+
+	if err := AddTranslation("xx", "a", "b"); err == nil {
+		t.Error("AddTranslation for an unregistered language succeeded")
+	}
+}
+
+func TestValidationMessages(t *testing.T) {
+	withFrench(t)
+	type signup struct {
+		Email string `validate:"required,email"`
+	}
+	err := ValidateStruct(signup{})
+
+	en := ValidationMessages(languageRequest("en"), err)
+	fr := ValidationMessages(languageRequest("fr"), err)
+	if en["signup.Email"] != "Email is a required field" {
+		t.Errorf("English messages: %v", en)
+	}
+	if msg := fr["signup.Email"]; msg == "" || msg == en["signup.Email"] || !strings.Contains(msg, "Email") {
+		t.Errorf("French messages: %v", fr)
+	}
+	if ValidationMessages(languageRequest("en"), io.EOF) != nil {
+		t.Error("ValidationMessages translated an error that is not from validation")
+	}
+}
@@ -0,0 +1,220 @@
+package router
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// JSON:API documents (https://jsonapi.org) for application/vnd.api+json.
+
+type jsonAPIDocument struct {
+	Data     interface{}        `json:"data"`
+	Included []*jsonAPIResource `json:"included,omitempty"`
+	Links    *PageLinks         `json:"links,omitempty"`
+	Meta     *PageMeta          `json:"meta,omitempty"`
+}
+
+type jsonAPIResource struct {
+	Type          string                          `json:"type"`
+	ID            string                          `json:"id,omitempty"`
+	Attributes    jsonObject                      `json:"attributes,omitempty"`
+	Relationships map[string]*jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string               `json:"links,omitempty"`
+}
+
+type jsonAPIRelationship struct {
+	Data  interface{}       `json:"data"`
+	Links map[string]string `json:"links,omitempty"`
+}
+
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// NewJSONAPIEncoder returns an encoder producing JSON:API documents from
+// structs described by resource and links tags:
+//
+//	type Article struct {
+//		ID       int       `json:"id" resource:"id,articles" links:"self=article"`
+//		Title    string    `json:"title"`
+//		Author   *Person   `json:"author" resource:"relation" links:"related=article-author"`
+//		Comments []Comment `json:"comments" resource:"relation"`
+//	}
+//
+// The resource tag marks the identifier, optionally followed by the resource
+// type (which defaults to the lower-cased type name), and relationship
+// fields. Every other field encoded by encoding/json is an attribute. A
+// links tag maps link relations to named routes, whose parameters are filled
+// from the resource's identifier, as "id", and from its attributes by json
+// name.
+//
+// A struct or pointer becomes a single resource and a slice a collection.
+// Related resources are referenced by identifier and included in full. A
+// PageEnvelope supplies the top-level pagination links and meta.
+//
+// Links are built from the named routes of routes; with a nil router they
+// are left out. An encoder without a router is registered for
+// application/vnd.api+json by default; register one with the application's
+// router to get links:
+//
+//	router.RegisterEncoder("application/vnd.api+json", router.NewJSONAPIEncoder(r))
+func NewJSONAPIEncoder(routes *Router) Encoder {
+	return EncoderFunc(func(w io.Writer, v interface{}) error {
+		data, links, meta := hypermediaDocument(v)
+		b := &jsonAPIBuilder{routes: routes, seen: map[jsonAPIIdentifier]bool{}}
+		doc := &jsonAPIDocument{Links: links, Meta: meta}
+
+		var err error
+		if doc.Data, err = b.primary(reflect.ValueOf(data)); err != nil {
+			return err
+		}
+		doc.Included = b.included
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	})
+}
+
+// jsonAPIBuilder collects the resources of one document, so that each one
+// appears only once.
+type jsonAPIBuilder struct {
+	routes   *Router
+	seen     map[jsonAPIIdentifier]bool
+	included []*jsonAPIResource
+	pending  []reflect.Value
+}
+
+func (b *jsonAPIBuilder) primary(v reflect.Value) (interface{}, error) {
+	v = indirectValue(v)
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	var data interface{}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		resources := make([]*jsonAPIResource, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			res, err := b.resource(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if res != nil {
+				resources = append(resources, res)
+			}
+		}
+		data = resources
+	} else {
+		res, err := b.resource(v)
+		if err != nil {
+			return nil, err
+		}
+		data = res
+	}
+
+	// Related resources are included after all primary data is known, so
+	// a resource that is both primary and related is not repeated.
+	for len(b.pending) > 0 {
+		next := b.pending[0]
+		b.pending = b.pending[1:]
+		res, err := b.resource(next)
+		if err != nil {
+			return nil, err
+		}
+		if res != nil {
+			b.included = append(b.included, res)
+		}
+	}
+	return data, nil
+}
+
+// resource builds the resource object for v, or returns nil when v is nil
+// or has already been added to the document.
+func (b *jsonAPIBuilder) resource(v reflect.Value) (*jsonAPIResource, error) {
+	rv, ok := resourceStruct(v)
+	if !ok {
+		if indirectValue(v).IsValid() {
+			return nil, fmt.Errorf("jsonapi: cannot encode %s as a resource", v.Type())
+		}
+		return nil, nil
+	}
+	v = rv
+	info := resourceInfoFor(v.Type())
+	id, err := info.resourceID(v)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" {
+		key := jsonAPIIdentifier{Type: info.typ, ID: id}
+		if b.seen[key] {
+			return nil, nil
+		}
+		b.seen[key] = true
+	}
+
+	res := &jsonAPIResource{Type: info.typ, ID: id, Attributes: info.attributes(v)}
+	if res.Links, err = info.resolveLinks(b.routes, v, info.links); err != nil {
+		return nil, err
+	}
+
+	for _, rel := range info.relations {
+		relationship := &jsonAPIRelationship{}
+		if relationship.Links, err = info.resolveLinks(b.routes, v, rel.links); err != nil {
+			return nil, err
+		}
+		fv, _ := fieldByIndex(v, rel.field.index)
+		if relationship.Data, err = b.linkage(fv); err != nil {
+			return nil, err
+		}
+		if res.Relationships == nil {
+			res.Relationships = map[string]*jsonAPIRelationship{}
+		}
+		res.Relationships[rel.field.name] = relationship
+	}
+	return res, nil
+}
+
+// linkage returns the resource identifiers for a relationship field and
+// queues the related resources for inclusion. To-one relationships without
+// a value are null and to-many relationships an empty array.
+func (b *jsonAPIBuilder) linkage(v reflect.Value) (interface{}, error) {
+	if v.IsValid() && (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) {
+		ids := make([]jsonAPIIdentifier, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			id, err := b.identifier(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			if id != nil {
+				ids = append(ids, *id)
+			}
+		}
+		return ids, nil
+	}
+
+	id, err := b.identifier(v)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+func (b *jsonAPIBuilder) identifier(v reflect.Value) (*jsonAPIIdentifier, error) {
+	rv, ok := resourceStruct(v)
+	if !ok {
+		return nil, nil
+	}
+	info := resourceInfoFor(rv.Type())
+	id, err := info.resourceID(rv)
+	if err != nil || id == "" {
+		return nil, err
+	}
+	key := jsonAPIIdentifier{Type: info.typ, ID: id}
+	if !b.seen[key] {
+		b.pending = append(b.pending, rv)
+	}
+	return &key, nil
+}
@@ -0,0 +1,111 @@
+package router
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestJSONAPIEncoder(t *testing.T) {
+	articles := hmArticles()
+	total := 2
+	tests := []struct {
+		name   string
+		routes *Router
+		v      interface{}
+		want   string
+	}{
+		{
+			"single resource with links",
+			hmRouter(),
+			&articles[0],
+			`{
+				"data": {
+					"type": "articles", "id": "1",
+					"attributes": {"title": "Hi"},
+					"relationships": {
+						"author": {"data": {"type": "people", "id": "9"}, "links": {"related": "/articles/1/author"}},
+						"comments": {"data": [{"type": "hmcomment", "id": "c1"}]}
+					},
+					"links": {"self": "/articles/1"}
+				},
+				"included": [
+					{"type": "people", "id": "9", "attributes": {"name": "Ann"}, "links": {"self": "/people/9"}},
+					{"type": "hmcomment", "id": "c1", "attributes": {"body": "Nice"}, "relationships": {"author": {"data": {"type": "people", "id": "9"}}}}
+				]
+			}`,
+		},
+		{
+			"collection without a router",
+			nil,
+			articles,
+			`{
+				"data": [
+					{
+						"type": "articles", "id": "1",
+						"attributes": {"title": "Hi"},
+						"relationships": {
+							"author": {"data": {"type": "people", "id": "9"}},
+							"comments": {"data": [{"type": "hmcomment", "id": "c1"}]}
+						}
+					},
+					{
+						"type": "articles", "id": "2",
+						"attributes": {"title": "Draft", "draft": true},
+						"relationships": {"author": {"data": null}, "comments": {"data": []}}
+					}
+				],
+				"included": [
+					{"type": "people", "id": "9", "attributes": {"name": "Ann"}},
+					{"type": "hmcomment", "id": "c1", "attributes": {"body": "Nice"}, "relationships": {"author": {"data": {"type": "people", "id": "9"}}}}
+				]
+			}`,
+		},
+		{
+			"page",
+			nil,
+			PageEnvelope{
+				Data:  []hmPerson{{ID: 9, Name: "Ann"}},
+				Meta:  PageMeta{Page: 1, PerPage: 1, Total: &total},
+				Links: PageLinks{First: "/people?page=1", Next: "/people?page=2"},
+			},
+			`{
+				"data": [{"type": "people", "id": "9", "attributes": {"name": "Ann"}}],
+				"links": {"first": "/people?page=1", "next": "/people?page=2"},
+				"meta": {"page": 1, "per_page": 1, "total": 2}
+			}`,
+		},
+		{"nil", nil, (*hmArticle)(nil), `{"data": null}`},
+		{"related resource already primary", nil, []hmPerson{{ID: 9, Name: "Ann"}, {ID: 9, Name: "Ann"}}, `{"data": [{"type": "people", "id": "9", "attributes": {"name": "Ann"}}]}`},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := NewJSONAPIEncoder(tt.routes).Encode(&buf, tt.v); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !jsonEqual(t, buf.Bytes(), []byte(tt.want)) {
+			t.Errorf("%s: got\n%s", tt.name, buf.String())
+		}
+	}
+}
+
+func TestJSONAPIEncoderErrors(t *testing.T) {
+	r := NewRouter()
+	r.Handle("GET", "/articles/{id:int}/{slug}", nil).Name("article")
+	tests := []struct {
+		name   string
+		routes *Router
+		v      interface{}
+	}{
+		{"not a struct", nil, []int{1}},
+		{"unbuildable link", r, hmArticle{ID: 1}},
+		{"unknown route", NewRouter(), hmArticle{ID: 1}},
+	}
+
+	for _, tt := range tests {
+		if err := NewJSONAPIEncoder(tt.routes).Encode(&bytes.Buffer{}, tt.v); err == nil {
+			t.Errorf("%s: encoding succeeded", tt.name)
+		}
+	}
+}
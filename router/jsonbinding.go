@@ -0,0 +1,293 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// JSONOptions configures NewJSONBinder.
+type JSONOptions struct {
+	// DisallowUnknownFields rejects objects with keys that match no field of
+	// the target struct.
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers into interface{} values as json.Number
+	// instead of float64.
+	UseNumber bool
+	// SingleValue rejects bodies with anything but whitespace after the
+	// first JSON value.
+	SingleValue bool
+	// MaxBytes caps the size of the body. Larger bodies yield a
+	// *RequestTooLargeError. Zero means no limit.
+	MaxBytes int64
+}
+
+// JSONError reports where a JSON body failed to decode.
+type JSONError struct {
+	// Path locates the failing value, such as $.items[2].qty.
+	Path string
+	// Offset is the byte offset in the body at which decoding failed.
+	Offset int64
+	Err    error
+}
+
+func (e *JSONError) Error() string {
+	return fmt.Sprintf("invalid JSON at %s (offset %d): %s", e.Path, e.Offset, strings.TrimPrefix(e.Err.Error(), "json: "))
+}
+
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns 400 Bad Request.
+func (e *JSONError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// NewJSONBinder returns a Binder that decodes JSON bodies as configured by
+// opts, whatever their Content-Type. Register it to make Bind strict:
+//
+//	router.RegisterBinder("application/json", router.NewJSONBinder(router.JSONOptions{
+//		DisallowUnknownFields: true,
+//		SingleValue:           true,
+//		MaxBytes:              1 << 20,
+//	}))
+//
+// Malformed bodies yield a *JSONError. A body cut short by MaxBytes, or by
+// an http.MaxBytesReader installed earlier, yields a *RequestTooLargeError.
+func NewJSONBinder(opts JSONOptions) Binder {
+	return BinderFunc(func(r *http.Request, v interface{}) error {
+		return decodeJSONWith(r, v, opts)
+	})
+}
+
+func decodeJSONWith(r *http.Request, v interface{}, opts JSONOptions) error {
+	if r.Body == nil {
+		return errors.New("request body is empty")
+	}
+
+	body := r.Body
+	if opts.MaxBytes > 0 {
+		body = http.MaxBytesReader(nil, body, opts.MaxBytes)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return &RequestTooLargeError{Limit: maxBytes.Limit}
+		}
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if opts.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if opts.UseNumber {
+		decoder.UseNumber()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return jsonError(data, v, err)
+	}
+
+	if opts.SingleValue {
+		offset := decoder.InputOffset()
+		if rest := bytes.TrimLeft(data[offset:], " \t\r\n"); len(rest) > 0 {
+			offset = int64(len(data) - len(rest))
+			return &JSONError{Path: "$", Offset: offset, Err: errors.New("unexpected data after the JSON value")}
+		}
+	}
+	return nil
+}
+
+// jsonError locates err, returned while decoding data into v.
+func jsonError(data []byte, v interface{}, err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		return &JSONError{Path: jsonPathAt(data, syntaxErr.Offset), Offset: syntaxErr.Offset, Err: err}
+	case errors.As(err, &typeErr):
+		return &JSONError{Path: jsonPathAt(data, typeErr.Offset), Offset: typeErr.Offset, Err: err}
+	case err == io.EOF:
+		return &JSONError{Path: "$", Err: errors.New("empty body")}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		offset := int64(len(data))
+		return &JSONError{Path: jsonPathAt(data, offset), Offset: offset, Err: err}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		if path, offset, ok := unknownJSONField(data, reflect.TypeOf(v)); ok {
+			return &JSONError{Path: path, Offset: offset, Err: err}
+		}
+	}
+	return &JSONError{Path: "$", Err: err}
+}
+
+// jsonFrame is an object or array being read by the path scanners.
+type jsonFrame struct {
+	array   bool
+	index   int
+	key     string
+	wantKey bool
+	// typ is the Go type the container decodes into, or nil if unknown.
+	typ reflect.Type
+}
+
+// jsonPath formats the location of the innermost value in frames.
+func jsonPath(frames []*jsonFrame) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, f := range frames {
+		switch {
+		case f.array && f.index >= 0:
+			b.WriteString("[" + strconv.Itoa(f.index) + "]")
+		case !f.array && f.key != "":
+			b.WriteString("." + f.key)
+		}
+	}
+	return b.String()
+}
+
+// scanJSON reads the tokens of data, keeping frames up to date. visit is
+// called with each object key and the offset where it starts; returning
+// false stops the scan. scanJSON returns the frames open when the input
+// ended or the scan stopped.
+func scanJSON(data []byte, t reflect.Type, visit func(frames []*jsonFrame, key string, offset int64) bool) []*jsonFrame {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var frames []*jsonFrame
+	valueDone := func() {
+		if len(frames) > 0 && !frames[len(frames)-1].array {
+			frames[len(frames)-1].wantKey = true
+		}
+	}
+
+	for {
+		start := decoder.InputOffset()
+		start += int64(len(data[start:]) - len(bytes.TrimLeft(data[start:], " \t\r\n,:")))
+		tok, err := decoder.Token()
+		if err != nil {
+			return frames
+		}
+		if d, ok := tok.(json.Delim); ok && (d == '}' || d == ']') {
+			frames = frames[:len(frames)-1]
+			valueDone()
+			continue
+		}
+
+		// The Go type of the value this token starts.
+		var typ reflect.Type
+		if len(frames) > 0 {
+			top := frames[len(frames)-1]
+			if !top.array && top.wantKey {
+				top.key, top.wantKey = tok.(string), false
+				if visit != nil && !visit(frames, top.key, start) {
+					return frames
+				}
+				continue
+			}
+			if top.array {
+				top.index++
+			}
+			typ = jsonChildType(top)
+		} else {
+			typ = t
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			frames = append(frames, &jsonFrame{wantKey: true, typ: jsonContainerType(typ)})
+		case json.Delim('['):
+			frames = append(frames, &jsonFrame{array: true, index: -1, typ: jsonContainerType(typ)})
+		default:
+			valueDone()
+		}
+	}
+}
+
+// jsonPathAt returns the path of the value being read at offset in data.
+func jsonPathAt(data []byte, offset int64) string {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return jsonPath(scanJSON(data[:offset], nil, nil))
+}
+
+// unknownJSONField finds the first object key in data that matches no field
+// of the struct it decodes into, returning its path and offset.
+func unknownJSONField(data []byte, t reflect.Type) (string, int64, bool) {
+	var (
+		path   string
+		offset int64
+		found  bool
+	)
+	scanJSON(data, t, func(frames []*jsonFrame, key string, start int64) bool {
+		top := frames[len(frames)-1]
+		if top.typ == nil || top.typ.Kind() != reflect.Struct || jsonStructField(top.typ, key) != nil {
+			return true
+		}
+		path, offset, found = jsonPath(frames), start, true
+		return false
+	})
+	return path, offset, found
+}
+
+// jsonContainerType returns the type an object or array decodes into when
+// its value has type t, or nil when decoding is not plain reflection.
+func jsonContainerType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		if t.Implements(jsonUnmarshalerType) {
+			return nil
+		}
+		t = t.Elem()
+	}
+	if t == nil || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return t
+	}
+	return nil
+}
+
+// jsonChildType returns the Go type of the next value in frame f.
+func jsonChildType(f *jsonFrame) reflect.Type {
+	if f.typ == nil {
+		return nil
+	}
+	switch f.typ.Kind() {
+	case reflect.Struct:
+		if sf := jsonStructField(f.typ, f.key); sf != nil {
+			return sf.typ
+		}
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return f.typ.Elem()
+	}
+	return nil
+}
+
+// jsonStructField finds the field of t that encoding/json stores key in,
+// preferring an exact match to a case-insensitive one.
+func jsonStructField(t reflect.Type, key string) *structField {
+	fields := structFields(t, "json")
+	for i := range fields {
+		if fields[i].name == key {
+			return &fields[i]
+		}
+	}
+	for i := range fields {
+		if strings.EqualFold(fields[i].name, key) {
+			return &fields[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type jsonLine struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type jsonOrder struct {
+	Name  string                 `json:"name"`
+	Items []jsonLine             `json:"items"`
+	Attrs map[string]jsonLine    `json:"attrs"`
+	Extra map[string]interface{} `json:"extra"`
+	Raw   json.RawMessage        `json:"raw"`
+}
+
+func jsonBodyRequest(body string) *http.Request {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+func TestJSONErrorLocation(t *testing.T) {
+	strict := JSONOptions{DisallowUnknownFields: true, SingleValue: true}
+	tests := []struct {
+		name   string
+		opts   JSONOptions
+		body   string
+		path   string
+		offset int64
+	}{
+		{"type", JSONOptions{}, `{"items":[{"qty":1},{"qty":"x"}]}`, "$.items[1].qty", 30},
+		{"map value", JSONOptions{}, `{"attrs":{"a":{"qty":true}}}`, "$.attrs.a.qty", 25},
+		{"syntax", JSONOptions{}, `{"name":"a","items":[{"qty":1}}`, "$.items[0]", 31},
+		{"truncated", JSONOptions{}, `{"items":[{"qty":1},`, "$.items[0]", 20},
+		{"empty", JSONOptions{}, ``, "$", 0},
+		{"top-level type", JSONOptions{}, `[1]`, "$", 1},
+		{"unknown field", strict, `{"name":"a","items":[{"qty":1,"colour":"red"}]}`, "$.items[0].colour", 30},
+		{"unknown top-level field", strict, `{"nope":1}`, "$.nope", 1},
+		{"trailing data", strict, `{"name":"a"}  {"name":"b"}`, "$", 14},
+	}
+
+	for _, tt := range tests {
+		err := NewJSONBinder(tt.opts).Bind(jsonBodyRequest(tt.body), &jsonOrder{})
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) {
+			t.Errorf("%s: error = %v, want *JSONError", tt.name, err)
+			continue
+		}
+		if jsonErr.Path != tt.path || jsonErr.Offset != tt.offset || jsonErr.StatusCode() != http.StatusBadRequest {
+			t.Errorf("%s: got %s at %d, want %s at %d (%v)", tt.name, jsonErr.Path, jsonErr.Offset, tt.path, tt.offset, err)
+		}
+	}
+}
+
+func TestJSONBinderOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts JSONOptions
+		body string
+		ok   bool
+	}{
+		{"unknown field allowed", JSONOptions{}, `{"name":"a","nope":1}`, true},
+		{"case-insensitive match is known", JSONOptions{DisallowUnknownFields: true}, `{"NAME":"a"}`, true},
+		{"unknown keys inside maps", JSONOptions{DisallowUnknownFields: true}, `{"extra":{"anything":1},"raw":{"x":1}}`, true},
+		{"trailing data allowed", JSONOptions{}, `{"name":"a"} junk`, true},
+		{"trailing whitespace", JSONOptions{SingleValue: true}, "{\"name\":\"a\"} \r\n\t", true},
+		{"under the limit", JSONOptions{MaxBytes: 12}, `{"name":"a"}`, true},
+	}
+
+	for _, tt := range tests {
+		if err := NewJSONBinder(tt.opts).Bind(jsonBodyRequest(tt.body), &jsonOrder{}); (err == nil) != tt.ok {
+			t.Errorf("%s: error = %v", tt.name, err)
+		}
+	}
+
+	var v jsonOrder
+	if err := NewJSONBinder(JSONOptions{UseNumber: true}).Bind(jsonBodyRequest(`{"extra":{"n":12345678901234567890}}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := v.Extra["n"].(json.Number); !ok || n.String() != "12345678901234567890" {
+		t.Errorf("UseNumber decoded %T %v", v.Extra["n"], v.Extra["n"])
+	}
+}
+
+func TestJSONBinderMaxBytes(t *testing.T) {
+	body := `{"name":"` + strings.Repeat("a", 100) + `"}`
+	var tooLarge *RequestTooLargeError
+
+	err := NewJSONBinder(JSONOptions{MaxBytes: 64}).Bind(jsonBodyRequest(body), &jsonOrder{})
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 64 || tooLarge.StatusCode() != http.StatusRequestEntityTooLarge {
+		t.Errorf("MaxBytes: error = %v, want *RequestTooLargeError", err)
+	}
+
+	// A limit set earlier in the chain is reported the same way.
+	r := jsonBodyRequest(body)
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 32)
+	if err := Bind(r, &jsonOrder{}); !errors.As(err, &tooLarge) || tooLarge.Limit != 32 {
+		t.Errorf("MaxBytesReader: error = %v, want *RequestTooLargeError", err)
+	}
+}
@@ -17,6 +17,10 @@ type contextKey string
 const (
 	requestIDKey   contextKey = "requestID"
 	contentTypeKey contextKey = "content-type"
+	cacheTagsKey   contextKey = "cache-tags"
+	languageKey    contextKey = "language"
+	paramsKey      contextKey = "params"
+	csrfKey        contextKey = "csrf"
 )
 
 var (
@@ -78,18 +82,17 @@ func RateLimitMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// ContentNegotiationMiddleware picks the response media type from the Accept
+// header, replying 406 Not Acceptable when no registered encoder matches the
+// accepted types. Handlers read the decision with NegotiatedContentType.
 func ContentNegotiationMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		acceptHeader := r.Header.Get("Accept")
-		var contentType string
-
-		switch {
-		case strings.Contains(acceptHeader, "application/json"):
-			contentType = "application/json"
-		case strings.Contains(acceptHeader, "application/xml"):
-			contentType = "application/xml"
-		default:
-			contentType = "text/html" // or any default content type
+		addVary(w.Header(), "Accept")
+
+		contentType := NegotiateContentType(r, encoderMediaTypes())
+		if contentType == "" {
+			http.Error(w, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+			return
 		}
 
 		ctx := context.WithValue(r.Context(), contentTypeKey, contentType)
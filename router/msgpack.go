@@ -0,0 +1,344 @@
+package router
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"time"
+)
+
+// MsgPackEncoder renders values as MessagePack, registered for
+// application/msgpack and application/x-msgpack.
+var MsgPackEncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+	data, err := MarshalMsgPack(v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+})
+
+// MarshalMsgPack encodes v as MessagePack. Structs become maps keyed by
+// their json tag names, and time.Time uses the timestamp extension type.
+func MarshalMsgPack(v interface{}) ([]byte, error) {
+	var mw msgpackWriter
+	if err := encodeBinary(&mw, reflect.ValueOf(v), 0); err != nil {
+		return nil, fmt.Errorf("msgpack: %v", err)
+	}
+	return mw.buf.Bytes(), nil
+}
+
+// UnmarshalMsgPack decodes a MessagePack document into v, which must be a
+// non-nil pointer. Map keys are matched to struct fields by json tag name.
+func UnmarshalMsgPack(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("msgpack: Unmarshal(non-pointer %T)", v)
+	}
+
+	br := &binaryReader{data: data}
+	decoded, err := readMsgPack(br, 0)
+	if err != nil {
+		return fmt.Errorf("msgpack: %v at offset %d", err, br.pos)
+	}
+	if br.pos != len(data) {
+		return fmt.Errorf("msgpack: unexpected data after top-level value at offset %d", br.pos)
+	}
+	if err := assignDecoded(rv.Elem(), decoded, ""); err != nil {
+		return fmt.Errorf("msgpack: %v", err)
+	}
+	return nil
+}
+
+type msgpackWriter struct {
+	buf bytes.Buffer
+}
+
+func (mw *msgpackWriter) writeNil() {
+	mw.buf.WriteByte(0xc0)
+}
+
+func (mw *msgpackWriter) writeBool(b bool) {
+	if b {
+		mw.buf.WriteByte(0xc3)
+	} else {
+		mw.buf.WriteByte(0xc2)
+	}
+}
+
+func (mw *msgpackWriter) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		mw.writeUint(uint64(i))
+	case i >= -32:
+		mw.buf.WriteByte(byte(i))
+	case i >= math.MinInt8:
+		mw.buf.Write([]byte{0xd0, byte(i)})
+	case i >= math.MinInt16:
+		mw.buf.WriteByte(0xd1)
+		mw.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(i)))
+	case i >= math.MinInt32:
+		mw.buf.WriteByte(0xd2)
+		mw.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(i)))
+	default:
+		mw.buf.WriteByte(0xd3)
+		mw.buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+	}
+}
+
+func (mw *msgpackWriter) writeUint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		mw.buf.WriteByte(byte(u))
+	case u <= math.MaxUint8:
+		mw.buf.Write([]byte{0xcc, byte(u)})
+	case u <= math.MaxUint16:
+		mw.buf.WriteByte(0xcd)
+		mw.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(u)))
+	case u <= math.MaxUint32:
+		mw.buf.WriteByte(0xce)
+		mw.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(u)))
+	default:
+		mw.buf.WriteByte(0xcf)
+		mw.buf.Write(binary.BigEndian.AppendUint64(nil, u))
+	}
+}
+
+func (mw *msgpackWriter) writeFloat32(f float32) {
+	mw.buf.WriteByte(0xca)
+	mw.buf.Write(binary.BigEndian.AppendUint32(nil, math.Float32bits(f)))
+}
+
+func (mw *msgpackWriter) writeFloat64(f float64) {
+	mw.buf.WriteByte(0xcb)
+	mw.buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
+
+func (mw *msgpackWriter) writeString(s string) {
+	mw.writeLength(len(s), 0xa0, 32, 0xd9, 0xda, 0xdb)
+	mw.buf.WriteString(s)
+}
+
+func (mw *msgpackWriter) writeBytes(b []byte) {
+	mw.writeLength(len(b), 0, 0, 0xc4, 0xc5, 0xc6)
+	mw.buf.Write(b)
+}
+
+// writeTime uses the 96-bit timestamp extension, which covers every
+// time.Time.
+func (mw *msgpackWriter) writeTime(t time.Time) {
+	mw.buf.Write([]byte{0xc7, 12, 0xff})
+	mw.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(t.Nanosecond())))
+	mw.buf.Write(binary.BigEndian.AppendUint64(nil, uint64(t.Unix())))
+}
+
+func (mw *msgpackWriter) writeArrayHeader(n int) {
+	mw.writeLength(n, 0x90, 16, 0, 0xdc, 0xdd)
+}
+
+func (mw *msgpackWriter) writeMapHeader(n int) {
+	mw.writeLength(n, 0x80, 16, 0, 0xde, 0xdf)
+}
+
+// writeLength writes a length prefix using the fix form when n is below
+// fixLimit, then the 8-, 16- and 32-bit forms. A zero code skips that form.
+func (mw *msgpackWriter) writeLength(n int, fix byte, fixLimit int, code8, code16, code32 byte) {
+	switch {
+	case n < fixLimit:
+		mw.buf.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		mw.buf.Write([]byte{code8, byte(n)})
+	case n <= math.MaxUint16:
+		mw.buf.WriteByte(code16)
+		mw.buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		mw.buf.WriteByte(code32)
+		mw.buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func readMsgPack(br *binaryReader, depth int) (interface{}, error) {
+	if depth > maxBinaryDepth {
+		return nil, fmt.Errorf("document nested too deeply")
+	}
+	code, err := br.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code <= 0x7f:
+		return int64(code), nil
+	case code >= 0xe0:
+		return int64(int8(code)), nil
+	case code&0xe0 == 0xa0:
+		return readMsgPackString(br, uint64(code&0x1f))
+	case code&0xf0 == 0x90:
+		return readMsgPackArray(br, uint64(code&0x0f), depth)
+	case code&0xf0 == 0x80:
+		return readMsgPackMap(br, uint64(code&0x0f), depth)
+	}
+
+	switch code {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return readMsgPackUint(br, 1<<(code-0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		u, err := readMsgPackUint(br, 1<<(code-0xd0))
+		if err != nil {
+			return nil, err
+		}
+		switch code {
+		case 0xd0:
+			return int64(int8(u)), nil
+		case 0xd1:
+			return int64(int16(u)), nil
+		case 0xd2:
+			return int64(int32(u)), nil
+		}
+		return int64(u), nil
+	case 0xca:
+		u, err := readMsgPackUint(br, 4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(uint32(u))), nil
+	case 0xcb:
+		u, err := readMsgPackUint(br, 8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(u), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := readMsgPackUint(br, 1<<(code-0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackString(br, n)
+	case 0xc4, 0xc5, 0xc6:
+		n, err := readMsgPackUint(br, 1<<(code-0xc4))
+		if err != nil {
+			return nil, err
+		}
+		size, err := br.checkCount(n, 1)
+		if err != nil {
+			return nil, err
+		}
+		b, err := br.next(size)
+		return append([]byte(nil), b...), err
+	case 0xdc, 0xdd:
+		n, err := readMsgPackUint(br, 2<<(code-0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackArray(br, n, depth)
+	case 0xde, 0xdf:
+		n, err := readMsgPackUint(br, 2<<(code-0xde))
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackMap(br, n, depth)
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return readMsgPackExt(br, 1<<(code-0xd4))
+	case 0xc7, 0xc8, 0xc9:
+		n, err := readMsgPackUint(br, 1<<(code-0xc7))
+		if err != nil {
+			return nil, err
+		}
+		size, err := br.checkCount(n, 1)
+		if err != nil {
+			return nil, err
+		}
+		return readMsgPackExt(br, size)
+	}
+	return nil, fmt.Errorf("invalid type code 0x%02x", code)
+}
+
+func readMsgPackUint(br *binaryReader, size int) (uint64, error) {
+	b, err := br.next(size)
+	if err != nil {
+		return 0, err
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return u, nil
+}
+
+func readMsgPackString(br *binaryReader, n uint64) (interface{}, error) {
+	size, err := br.checkCount(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := br.next(size)
+	return string(b), err
+}
+
+func readMsgPackArray(br *binaryReader, n uint64, depth int) (interface{}, error) {
+	count, err := br.checkCount(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]interface{}, count)
+	for i := range items {
+		if items[i], err = readMsgPack(br, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+func readMsgPackMap(br *binaryReader, n uint64, depth int) (interface{}, error) {
+	count, err := br.checkCount(n, 2)
+	if err != nil {
+		return nil, err
+	}
+	entries := make(decodedMap, count)
+	for i := range entries {
+		if entries[i].key, err = readMsgPack(br, depth+1); err != nil {
+			return nil, err
+		}
+		if entries[i].value, err = readMsgPack(br, depth+1); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// readMsgPackExt decodes extension data. Only the timestamp type (-1) is
+// understood; other extensions are returned as their raw bytes.
+func readMsgPackExt(br *binaryReader, size int) (interface{}, error) {
+	typ, err := br.byte()
+	if err != nil {
+		return nil, err
+	}
+	data, err := br.next(size)
+	if err != nil {
+		return nil, err
+	}
+	if int8(typ) != -1 {
+		return append([]byte(nil), data...), nil
+	}
+
+	switch size {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(data)), 0).UTC(), nil
+	case 8:
+		v := binary.BigEndian.Uint64(data)
+		return time.Unix(int64(v&(1<<34-1)), int64(v>>34)).UTC(), nil
+	case 12:
+		nsec := binary.BigEndian.Uint32(data[:4])
+		sec := int64(binary.BigEndian.Uint64(data[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return nil, fmt.Errorf("invalid timestamp length %d", size)
+}
@@ -0,0 +1,102 @@
+package router
+
+import (
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Encodings from the MessagePack specification.
+func TestMarshalMsgPack(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{0, "00"},
+		{127, "7f"},
+		{128, "cc80"},
+		{256, "cd0100"},
+		{uint64(1 << 32), "cf0000000100000000"},
+		{-1, "ff"},
+		{-32, "e0"},
+		{-33, "d0df"},
+		{-129, "d1ff7f"},
+		{-40000, "d2ffff63c0"},
+		{int64(-1 << 40), "d3ffffff0000000000"},
+		{float32(1.5), "ca3fc00000"},
+		{1.5, "cb3ff8000000000000"},
+		{nil, "c0"},
+		{false, "c2"},
+		{true, "c3"},
+		{"a", "a161"},
+		{strings.Repeat("x", 32), "d920" + strings.Repeat("78", 32)},
+		{[]byte{1, 2}, "c4020102"},
+		{[]int{1, 2}, "920102"},
+		{map[string]bool{"a": true}, "81a161c3"},
+		{time.Unix(1, 2).UTC(), "c70cff000000020000000000000001"},
+	}
+
+	for _, tt := range tests {
+		data, err := MarshalMsgPack(tt.v)
+		if err != nil {
+			t.Errorf("MarshalMsgPack(%#v): %v", tt.v, err)
+			continue
+		}
+		if got := hex.EncodeToString(data); got != tt.want {
+			t.Errorf("MarshalMsgPack(%#v) = %s, want %s", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalMsgPack(t *testing.T) {
+	tests := []struct {
+		data string
+		want interface{}
+	}{
+		{"ff", -1},
+		{"d3ffffff0000000000", int64(-1 << 40)},
+		{"cf0000000100000000", uint64(1 << 32)},
+		{"ca3fc00000", 1.5},
+		{"da000161", "a"},
+		{"c5000101", []byte{1}},
+		{"dc0002c3c2", []bool{true, false}},
+		{"de0001a161c3", map[string]bool{"a": true}},
+		{"d6ff00000001", time.Unix(1, 0).UTC()},
+		{"d7ff0000000800000001", time.Unix(1, 2).UTC()},
+	}
+
+	for _, tt := range tests {
+		data, err := hex.DecodeString(tt.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := reflect.New(reflect.TypeOf(tt.want))
+		if err := UnmarshalMsgPack(data, got.Interface()); err != nil {
+			t.Errorf("UnmarshalMsgPack(%s): %v", tt.data, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.Elem().Interface(), tt.want) {
+			t.Errorf("UnmarshalMsgPack(%s) = %#v, want %#v", tt.data, got.Elem().Interface(), tt.want)
+		}
+	}
+}
+
+func TestUnmarshalMsgPackErrors(t *testing.T) {
+	for _, data := range []string{
+		"",
+		"c1",         // never used
+		"cd01",       // truncated integer
+		"a261",       // truncated string
+		"92c3",       // truncated array
+		"dbffffffff", // length beyond the data
+		"0000",       // trailing data
+	} {
+		b, _ := hex.DecodeString(data)
+		var v interface{}
+		if err := UnmarshalMsgPack(b, &v); err == nil {
+			t.Errorf("UnmarshalMsgPack(%s) = %#v, want an error", data, v)
+		}
+	}
+}
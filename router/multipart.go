@@ -0,0 +1,218 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+)
+
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.SliceOf(fileHeaderType)
+)
+
+// MultipartOptions configures BindMultipart. Zero limits mean no limit.
+type MultipartOptions struct {
+	// MaxMemory is how much of the form, files included, is held in memory
+	// before files spill to temporary files. Defaults to 32 MB.
+	MaxMemory int64
+	// MaxFileSize caps the size of each uploaded file. The body is read no
+	// further than the first file over the limit.
+	MaxFileSize int64
+	// MaxTotalSize caps the size of the whole request body.
+	MaxTotalSize int64
+}
+
+// RequestTooLargeError is returned when a request body exceeds a size
+// limit.
+type RequestTooLargeError struct {
+	// Limit is the limit that was exceeded, in bytes.
+	Limit int64
+}
+
+func (e *RequestTooLargeError) Error() string {
+	return fmt.Sprintf("request body exceeds %d bytes", e.Limit)
+}
+
+// StatusCode returns 413 Content Too Large.
+func (e *RequestTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// FileTooLargeError is returned by BindMultipart when an uploaded file
+// exceeds MaxFileSize.
+type FileTooLargeError struct {
+	// Name is the form key and Filename the client's name for the file.
+	Name     string
+	Filename string
+	// Size is the number of bytes read from the file before the limit was
+	// found to be exceeded; the file may be larger.
+	Size  int64
+	Limit int64
+}
+
+func (e *FileTooLargeError) Error() string {
+	return fmt.Sprintf("file %q in %q exceeds the limit of %d bytes", e.Filename, e.Name, e.Limit)
+}
+
+// StatusCode returns 413 Content Too Large.
+func (e *FileTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
+// BindMultipart decodes a multipart/form-data request into v, a pointer to a
+// struct. Text parts are bound as BindForm binds them; file parts are stored
+// in *multipart.FileHeader and []*multipart.FileHeader fields:
+//
+//	type Upload struct {
+//		Title       string                  `form:"title"`
+//		Avatar      *multipart.FileHeader   `form:"avatar"`
+//		Attachments []*multipart.FileHeader `form:"attachments"`
+//	}
+//
+// A body over MaxTotalSize yields a *RequestTooLargeError and a file over
+// MaxFileSize a *FileTooLargeError, both with status 413. Bind uses this
+// binder with default options for multipart/form-data.
+func BindMultipart(r *http.Request, v interface{}, opts MultipartOptions) error {
+	if !hasContentType(r, "multipart/form-data") {
+		return errors.New("content type is not multipart/form-data")
+	}
+	if opts.MaxMemory <= 0 {
+		opts.MaxMemory = 32 << 20
+	}
+
+	rv, err := bindTarget(v)
+	if err != nil {
+		return err
+	}
+
+	if opts.MaxTotalSize > 0 && r.Body != nil {
+		r.Body = http.MaxBytesReader(nil, r.Body, opts.MaxTotalSize)
+	}
+	if opts.MaxFileSize > 0 {
+		err = parseMultipartLimited(r, opts)
+	} else {
+		err = r.ParseMultipartForm(opts.MaxMemory)
+	}
+	if err != nil {
+		var (
+			maxBytes *http.MaxBytesError
+			tooLarge *FileTooLargeError
+		)
+		switch {
+		case errors.As(err, &maxBytes):
+			return &RequestTooLargeError{Limit: maxBytes.Limit}
+		case errors.As(err, &tooLarge):
+			return tooLarge
+		}
+		return err
+	}
+
+	tree := newFormTree(r.Form)
+	for name, files := range r.MultipartForm.File {
+		tree.addFiles(name, files)
+	}
+
+	var errs BindErrors
+	bindFormStruct(tree, rv, "", "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// parseMultipartLimited does the work of r.ParseMultipartForm, but stops
+// reading the body as soon as a file exceeds opts.MaxFileSize instead of
+// spooling it whole. The parts are streamed through a size check into a
+// second multipart stream, which multipart.Reader.ReadForm parses as usual.
+func parseMultipartLimited(r *http.Request, opts MultipartOptions) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(copyMultipartLimited(mw, mr, opts.MaxFileSize))
+	}()
+	form, err := multipart.NewReader(pr, mw.Boundary()).ReadForm(opts.MaxMemory)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+
+	r.MultipartForm = form
+	for key, values := range form.Value {
+		r.Form[key] = append(r.Form[key], values...)
+		r.PostForm[key] = append(r.PostForm[key], values...)
+	}
+	return nil
+}
+
+// copyMultipartLimited copies the parts of mr to mw, failing with a
+// *FileTooLargeError once a file part is longer than limit.
+func copyMultipartLimited(mw *multipart.Writer, mr *multipart.Reader, limit int64) error {
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return mw.Close()
+		}
+		if err != nil {
+			return err
+		}
+		w, err := mw.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		if part.FileName() == "" {
+			if _, err := io.Copy(w, part); err != nil {
+				return err
+			}
+			continue
+		}
+		n, err := io.Copy(w, io.LimitReader(part, limit+1))
+		if err != nil {
+			return err
+		}
+		if n > limit {
+			return &FileTooLargeError{Name: part.FormName(), Filename: part.FileName(), Size: n, Limit: limit}
+		}
+	}
+}
+
+// bindFormFiles stores the files of node in v if it is a file field, and
+// reports whether it was one.
+func bindFormFiles(node *formNode, v reflect.Value, key, path string, errs *BindErrors) bool {
+	switch v.Type() {
+	case fileHeaderType:
+		if len(node.files) > 0 {
+			v.Set(reflect.ValueOf(node.files[0]))
+		}
+	case fileHeadersType:
+		files := node.files
+		if c, ok := node.children[""]; ok {
+			files = append(files, c.files...)
+		}
+		elements, err := node.indexed()
+		if err != nil {
+			*errs = append(*errs, &BindError{Source: "form", Name: key, Field: path, Err: err})
+			return true
+		}
+		for _, c := range elements {
+			files = append(files, c.files...)
+		}
+		if len(files) > 0 {
+			v.Set(reflect.ValueOf(files))
+		}
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type uploadForm struct {
+	Title       string                  `form:"title"`
+	Page        int                     `form:"page"`
+	Avatar      *multipart.FileHeader   `form:"avatar"`
+	Attachments []*multipart.FileHeader `form:"attachments"`
+}
+
+type multipartPart struct {
+	name, filename, content string
+}
+
+func multipartBody(t *testing.T, parts ...multipartPart) (string, *bytes.Buffer) {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, p := range parts {
+		var w io.Writer
+		var err error
+		if p.filename != "" {
+			w, err = mw.CreateFormFile(p.name, p.filename)
+		} else {
+			w, err = mw.CreateFormField(p.name)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, p.content)
+	}
+	mw.Close()
+	return mw.FormDataContentType(), &body
+}
+
+func TestBindMultipart(t *testing.T) {
+	parts := []multipartPart{
+		{"title", "", "report"},
+		{"avatar", "me.png", "png-data"},
+		{"attachments", "a.txt", "aaa"},
+		{"attachments", "b.txt", "bbb"},
+	}
+
+	for _, opts := range []MultipartOptions{{}, {MaxFileSize: 8}, {MaxMemory: 1, MaxFileSize: 8}} {
+		contentType, body := multipartBody(t, parts...)
+		r := httptest.NewRequest("POST", "/?page=3", body)
+		r.Header.Set("Content-Type", contentType)
+
+		var got uploadForm
+		if err := BindMultipart(r, &got, opts); err != nil {
+			t.Fatalf("%+v: %v", opts, err)
+		}
+		if got.Title != "report" || got.Page != 3 {
+			t.Errorf("%+v: bound %+v", opts, got)
+		}
+		if got.Avatar == nil || got.Avatar.Filename != "me.png" || len(got.Attachments) != 2 {
+			t.Fatalf("%+v: files %+v, %+v", opts, got.Avatar, got.Attachments)
+		}
+		for _, f := range append([]*multipart.FileHeader{got.Avatar}, got.Attachments...) {
+			file, err := f.Open()
+			if err != nil {
+				t.Fatalf("%+v: open %s: %v", opts, f.Filename, err)
+			}
+			data, _ := io.ReadAll(file)
+			file.Close()
+			if len(data) != int(f.Size) || len(data) == 0 {
+				t.Errorf("%+v: %s holds %q, size %d", opts, f.Filename, data, f.Size)
+			}
+		}
+		if r.FormValue("title") != "report" {
+			t.Errorf("%+v: FormValue(title) = %q", opts, r.FormValue("title"))
+		}
+		r.MultipartForm.RemoveAll()
+	}
+}
+
+func TestBindMultipartLimits(t *testing.T) {
+	tests := []struct {
+		opts  MultipartOptions
+		parts []multipartPart
+		want  interface{}
+	}{
+		{MultipartOptions{MaxFileSize: 4}, []multipartPart{{"avatar", "me.png", "12345"}}, &FileTooLargeError{}},
+		{MultipartOptions{MaxFileSize: 5}, []multipartPart{{"avatar", "me.png", "12345"}}, nil},
+		// The limit applies to files, not to text fields.
+		{MultipartOptions{MaxFileSize: 4}, []multipartPart{{"title", "", "a long title"}}, nil},
+		{MultipartOptions{MaxTotalSize: 64}, []multipartPart{{"avatar", "me.png", strings.Repeat("x", 100)}}, &RequestTooLargeError{}},
+		{MultipartOptions{MaxTotalSize: 64, MaxFileSize: 1000}, []multipartPart{{"avatar", "me.png", strings.Repeat("x", 100)}}, &RequestTooLargeError{}},
+	}
+
+	for i, tt := range tests {
+		contentType, body := multipartBody(t, tt.parts...)
+		r := httptest.NewRequest("POST", "/", body)
+		r.Header.Set("Content-Type", contentType)
+
+		var v uploadForm
+		err := BindMultipart(r, &v, tt.opts)
+		switch want := tt.want.(type) {
+		case nil:
+			if err != nil {
+				t.Errorf("%d: %v", i, err)
+			}
+		case *FileTooLargeError:
+			if !errors.As(err, &want) || want.Name != "avatar" || want.Filename != "me.png" || want.StatusCode() != http.StatusRequestEntityTooLarge {
+				t.Errorf("%d: error = %v, want *FileTooLargeError", i, err)
+			}
+		case *RequestTooLargeError:
+			if !errors.As(err, &want) || want.StatusCode() != http.StatusRequestEntityTooLarge {
+				t.Errorf("%d: error = %v, want *RequestTooLargeError", i, err)
+			}
+		}
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func TestBindMultipartStopsAtFileLimit(t *testing.T) {
+	const size = 16 << 20
+	contentType, head := multipartBody(t, multipartPart{"avatar", "big.bin", ""})
+	// Splice a large file into the empty part, before the closing boundary.
+	raw := head.String()
+	split := strings.LastIndex(raw, "\r\n--")
+	body := &countingReader{r: io.MultiReader(
+		strings.NewReader(raw[:split]),
+		io.LimitReader(zeroReader{}, size),
+		strings.NewReader(raw[split:]),
+	)}
+	r := httptest.NewRequest("POST", "/", body)
+	r.Header.Set("Content-Type", contentType)
+
+	var v uploadForm
+	err := BindMultipart(r, &v, MultipartOptions{MaxFileSize: 1 << 10})
+	var tooLarge *FileTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("error = %v, want *FileTooLargeError", err)
+	}
+	if body.n > 1<<20 {
+		t.Errorf("read %d bytes of a %d byte body past a 1 KB file limit", body.n, size)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
@@ -0,0 +1,46 @@
+package router
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// NDJSONEncoder writes newline-delimited JSON. Slices and arrays produce one
+// line per element; a receive channel produces one line per value until it
+// is closed, flushing after each so clients see records as they are sent.
+// Any other value is written as a single line.
+var NDJSONEncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := enc.Encode(rv.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	case reflect.Chan:
+		if rv.Type().ChanDir()&reflect.RecvDir == 0 {
+			return &json.UnsupportedTypeError{Type: rv.Type()}
+		}
+		for {
+			item, ok := rv.Recv()
+			if !ok {
+				break
+			}
+			if err := enc.Encode(item.Interface()); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	default:
+		return enc.Encode(v)
+	}
+	return nil
+})
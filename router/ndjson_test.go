@@ -0,0 +1,67 @@
+package router
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNDJSONEncoder(t *testing.T) {
+	type event struct {
+		ID int `json:"id"`
+	}
+	records := make(chan event, 2)
+	records <- event{1}
+	records <- event{2}
+	close(records)
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"slice", []event{{1}, {2}}, "{\"id\":1}\n{\"id\":2}\n"},
+		{"array", [2]int{3, 4}, "3\n4\n"},
+		{"empty", []event{}, ""},
+		{"channel", (<-chan event)(records), "{\"id\":1}\n{\"id\":2}\n"},
+		{"single value", event{5}, "{\"id\":5}\n"},
+		{"map", map[string]int{"a": 1}, "{\"a\":1}\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := NDJSONEncoder.Encode(&buf, tt.v); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, buf.String(), tt.want)
+		}
+	}
+
+	for name, v := range map[string]interface{}{
+		"send-only channel": make(chan<- int),
+		"bad element":       []interface{}{1, func() {}},
+	} {
+		if err := NDJSONEncoder.Encode(&bytes.Buffer{}, v); err == nil {
+			t.Errorf("%s: encoding succeeded", name)
+		}
+	}
+}
+
+func TestNDJSONEncoderFlushes(t *testing.T) {
+	records := make(chan int)
+	w := httptest.NewRecorder()
+	done := make(chan error)
+	go func() { done <- NDJSONEncoder.Encode(w, (<-chan int)(records)) }()
+
+	records <- 1
+	records <- 2 // the first record has been written and flushed by now
+	close(records)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !w.Flushed || w.Body.String() != "1\n2\n" {
+		t.Errorf("flushed %t, body %q", w.Flushed, w.Body)
+	}
+}
@@ -0,0 +1,286 @@
+package router
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// PaginationOptions configures Paginate. Zero values select the defaults.
+type PaginationOptions struct {
+	// DefaultPerPage is used when the request has no per-page parameter.
+	// Defaults to 20.
+	DefaultPerPage int
+	// MaxPerPage caps the per-page parameter. Defaults to 100.
+	MaxPerPage int
+	// PageParam, PerPageParam and CursorParam name the query parameters.
+	// They default to "page", "per_page" and "cursor".
+	PageParam    string
+	PerPageParam string
+	CursorParam  string
+}
+
+// Page is the slice of a list requested by a client. Handlers read the
+// request fields to fetch their data, fill in the response fields, and call
+// Respond.
+type Page struct {
+	// Page is the 1-based page number in offset mode and zero in cursor mode.
+	Page int
+	// PerPage is the number of items to return.
+	PerPage int
+	// Cursor is the opaque cursor sent by the client. A non-empty Cursor
+	// selects cursor mode.
+	Cursor string
+
+	// Total is the number of items in the whole list, or -1 when unknown.
+	// It lets offset pages link to the last page.
+	Total int
+	// NextCursor and PrevCursor are the cursors of the adjacent pages in
+	// cursor mode; empty means there is no such page.
+	NextCursor string
+	PrevCursor string
+
+	opts PaginationOptions
+}
+
+// PageEnvelope wraps the items of a page in responses rendered by
+// Page.Respond.
+type PageEnvelope struct {
+	XMLName xml.Name    `json:"-" xml:"page"`
+	Data    interface{} `json:"data" xml:"data"`
+	Meta    PageMeta    `json:"meta" xml:"meta"`
+	Links   PageLinks   `json:"links" xml:"links"`
+}
+
+// PageMeta describes the page inside a PageEnvelope.
+type PageMeta struct {
+	Page       int    `json:"page,omitempty" xml:"page,omitempty"`
+	PerPage    int    `json:"per_page" xml:"per_page"`
+	Total      *int   `json:"total,omitempty" xml:"total,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty" xml:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty" xml:"prev_cursor,omitempty"`
+}
+
+// PageLinks holds the same links as the Link header.
+type PageLinks struct {
+	First string `json:"first,omitempty" xml:"first,omitempty"`
+	Prev  string `json:"prev,omitempty" xml:"prev,omitempty"`
+	Next  string `json:"next,omitempty" xml:"next,omitempty"`
+	Last  string `json:"last,omitempty" xml:"last,omitempty"`
+}
+
+// bareListTypes are media types that render a list on its own, so
+// Page.Respond sends the items without an envelope.
+var bareListTypes = map[string]bool{
+	"text/csv":             true,
+	"application/x-ndjson": true,
+}
+
+// Paginate reads the pagination parameters of r. It returns an error when a
+// page or per-page value is not a positive integer; per-page values above
+// MaxPerPage are clamped.
+func Paginate(r *http.Request, opts PaginationOptions) (*Page, error) {
+	if opts.DefaultPerPage <= 0 {
+		opts.DefaultPerPage = 20
+	}
+	if opts.MaxPerPage <= 0 {
+		opts.MaxPerPage = 100
+	}
+	if opts.PageParam == "" {
+		opts.PageParam = "page"
+	}
+	if opts.PerPageParam == "" {
+		opts.PerPageParam = "per_page"
+	}
+	if opts.CursorParam == "" {
+		opts.CursorParam = "cursor"
+	}
+
+	query := r.URL.Query()
+	p := &Page{PerPage: opts.DefaultPerPage, Total: -1, opts: opts}
+
+	if value := query.Get(opts.PerPageParam); value != "" {
+		perPage, err := strconv.Atoi(value)
+		if err != nil || perPage < 1 {
+			return nil, fmt.Errorf("invalid %s parameter %q", opts.PerPageParam, value)
+		}
+		p.PerPage = perPage
+	}
+	if p.PerPage > opts.MaxPerPage {
+		p.PerPage = opts.MaxPerPage
+	}
+
+	if p.Cursor = query.Get(opts.CursorParam); p.Cursor != "" {
+		return p, nil
+	}
+
+	p.Page = 1
+	if value := query.Get(opts.PageParam); value != "" {
+		page, err := strconv.Atoi(value)
+		// Pages past the end of the int range would overflow Offset.
+		if err != nil || page < 1 || page >= math.MaxInt/p.PerPage {
+			return nil, fmt.Errorf("invalid %s parameter %q", opts.PageParam, value)
+		}
+		p.Page = page
+	}
+	return p, nil
+}
+
+// Offset is the index of the first item on an offset page. It is clamped
+// to math.MaxInt rather than overflowing.
+func (p *Page) Offset() int {
+	if p.Page < 1 || p.PerPage < 1 {
+		return 0
+	}
+	if p.Page-1 > math.MaxInt/p.PerPage {
+		return math.MaxInt
+	}
+	return (p.Page - 1) * p.PerPage
+}
+
+// Limit is the maximum number of items on the page.
+func (p *Page) Limit() int {
+	return p.PerPage
+}
+
+// Respond sets an RFC 8288 Link header with first, prev, next and last links
+// built from the request URL, then renders items with Respond. Items are
+// wrapped in a PageEnvelope unless the negotiated type is a list format such
+// as CSV or NDJSON. Options are passed on to Respond.
+func (p *Page) Respond(w http.ResponseWriter, r *http.Request, status int, items interface{}, opts ...RespondOption) {
+	links := p.links(r, itemCount(items))
+	if header := links.header(); header != "" {
+		w.Header().Set("Link", header)
+	}
+
+	contentType := NegotiatedContentType(r)
+	if contentType == "" {
+		contentType = NegotiateContentType(r, encoderMediaTypes())
+	}
+	if bareListTypes[contentType] {
+		Respond(w, r, status, items, opts...)
+		return
+	}
+
+	envelope := PageEnvelope{
+		Data: items,
+		Meta: PageMeta{
+			Page:       p.Page,
+			PerPage:    p.PerPage,
+			NextCursor: p.NextCursor,
+			PrevCursor: p.PrevCursor,
+		},
+		Links: links,
+	}
+	if p.Total >= 0 {
+		total := p.Total
+		envelope.Meta.Total = &total
+	}
+	Respond(w, r, status, envelope, opts...)
+}
+
+func (p *Page) links(r *http.Request, count int) PageLinks {
+	var links PageLinks
+
+	if p.Cursor != "" || p.NextCursor != "" || p.PrevCursor != "" {
+		links.First = p.pageURL(r, "", 0)
+		if p.PrevCursor != "" {
+			links.Prev = p.pageURL(r, p.PrevCursor, 0)
+		}
+		if p.NextCursor != "" {
+			links.Next = p.pageURL(r, p.NextCursor, 0)
+		}
+		return links
+	}
+
+	links.First = p.pageURL(r, "", 1)
+	if p.Page > 1 {
+		links.Prev = p.pageURL(r, "", p.Page-1)
+	}
+	if p.Total >= 0 {
+		last := p.Total / p.PerPage
+		if p.Total%p.PerPage != 0 {
+			last++
+		}
+		if last < 1 {
+			last = 1
+		}
+		links.Last = p.pageURL(r, "", last)
+		if p.Page < last {
+			links.Next = p.pageURL(r, "", p.Page+1)
+		}
+	} else if count >= p.PerPage {
+		// Without a total, a full page suggests there is another one.
+		links.Next = p.pageURL(r, "", p.Page+1)
+	}
+	return links
+}
+
+// pageURL rewrites the request's path and query to point at another page,
+// keeping every unrelated query parameter.
+func (p *Page) pageURL(r *http.Request, cursor string, page int) string {
+	query := r.URL.Query()
+	query.Del(p.opts.PageParam)
+	query.Del(p.opts.CursorParam)
+	query.Set(p.opts.PerPageParam, strconv.Itoa(p.PerPage))
+	if cursor != "" {
+		query.Set(p.opts.CursorParam, cursor)
+	}
+	if page > 0 {
+		query.Set(p.opts.PageParam, strconv.Itoa(page))
+	}
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return u.String()
+}
+
+func (l PageLinks) header() string {
+	var parts []string
+	for _, link := range []struct{ rel, href string }{
+		{"first", l.First},
+		{"prev", l.Prev},
+		{"next", l.Next},
+		{"last", l.Last},
+	} {
+		if link.href != "" {
+			parts = append(parts, fmt.Sprintf(`<%s>; rel="%s"`, link.href, link.rel))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// itemCount returns the length of a slice or array, or -1 for other values.
+func itemCount(items interface{}) int {
+	v := reflect.ValueOf(items)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return v.Len()
+	}
+	return -1
+}
+
+// EncodeCursor turns v into an opaque, URL-safe cursor string.
+func EncodeCursor(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecodeCursor reverses EncodeCursor, storing the cursor's value in v.
+func DecodeCursor(cursor string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("invalid cursor: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid cursor: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package router
+
+import (
+	"math"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		query   string
+		opts    PaginationOptions
+		page    int
+		perPage int
+		cursor  string
+		offset  int
+		wantErr bool
+	}{
+		{query: "", page: 1, perPage: 20, offset: 0},
+		{query: "page=3&per_page=10", page: 3, perPage: 10, offset: 20},
+		{query: "per_page=500", page: 1, perPage: 100},
+		{query: "p=2&n=5", opts: PaginationOptions{PageParam: "p", PerPageParam: "n"}, page: 2, perPage: 5, offset: 5},
+		{query: "cursor=abc&page=4", perPage: 20, cursor: "abc"},
+		{query: "page=0", wantErr: true},
+		{query: "page=-1", wantErr: true},
+		{query: "page=x", wantErr: true},
+		{query: "per_page=0", wantErr: true},
+		{query: "page=99999999999999999999", wantErr: true},
+		// Pages whose offset would overflow are rejected.
+		{query: "page=" + strconv.Itoa(math.MaxInt), wantErr: true},
+		{query: "page=" + strconv.Itoa(math.MaxInt/20), wantErr: true},
+		{query: "page=" + strconv.Itoa(math.MaxInt/20-1), page: math.MaxInt/20 - 1, perPage: 20, offset: (math.MaxInt/20 - 2) * 20},
+	}
+
+	for _, tt := range tests {
+		p, err := Paginate(httptest.NewRequest("GET", "/items?"+tt.query, nil), tt.opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got page %+v, want an error", tt.query, p)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if p.Page != tt.page || p.PerPage != tt.perPage || p.Cursor != tt.cursor || p.Offset() != tt.offset {
+			t.Errorf("%s: got page %d, per page %d, cursor %q, offset %d", tt.query, p.Page, p.PerPage, p.Cursor, p.Offset())
+		}
+		if p.Offset() < 0 {
+			t.Errorf("%s: negative offset %d", tt.query, p.Offset())
+		}
+	}
+}
+
+func TestPageOffsetClamped(t *testing.T) {
+	p := &Page{Page: math.MaxInt, PerPage: 100}
+	if got := p.Offset(); got != math.MaxInt {
+		t.Errorf("Offset() = %d, want math.MaxInt", got)
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	tests := []struct {
+		target string
+		total  int
+		count  int
+		want   PageLinks
+	}{
+		{
+			"/items?page=2&per_page=10&q=x", 35, 10,
+			PageLinks{
+				First: "/items?page=1&per_page=10&q=x",
+				Prev:  "/items?page=1&per_page=10&q=x",
+				Next:  "/items?page=3&per_page=10&q=x",
+				Last:  "/items?page=4&per_page=10&q=x",
+			},
+		},
+		{
+			"/items?page=4&per_page=10", 40, 10,
+			PageLinks{
+				First: "/items?page=1&per_page=10",
+				Prev:  "/items?page=3&per_page=10",
+				Last:  "/items?page=4&per_page=10",
+			},
+		},
+		{
+			"/items", 0, 0,
+			PageLinks{First: "/items?page=1&per_page=20", Last: "/items?page=1&per_page=20"},
+		},
+		{
+			"/items?per_page=2", -1, 2,
+			PageLinks{First: "/items?page=1&per_page=2", Next: "/items?page=2&per_page=2"},
+		},
+		{
+			"/items?per_page=2", -1, 1,
+			PageLinks{First: "/items?page=1&per_page=2"},
+		},
+		{
+			"/items?page=2", math.MaxInt, 20,
+			PageLinks{
+				First: "/items?page=1&per_page=20",
+				Prev:  "/items?page=1&per_page=20",
+				Next:  "/items?page=3&per_page=20",
+				Last:  "/items?page=" + strconv.Itoa(math.MaxInt/20+1) + "&per_page=20",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		p, err := Paginate(r, PaginationOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Total = tt.total
+		if got := p.links(r, tt.count); got != tt.want {
+			t.Errorf("%s (total %d): links = %+v, want %+v", tt.target, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestPageCursorLinks(t *testing.T) {
+	r := httptest.NewRequest("GET", "/items?cursor=b&q=x", nil)
+	p, err := Paginate(r, PaginationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.NextCursor, p.PrevCursor = "c", "a"
+
+	w := httptest.NewRecorder()
+	p.Respond(w, r, 200, []int{1, 2})
+	link := w.Header().Get("Link")
+	for _, want := range []string{
+		`</items?per_page=20&q=x>; rel="first"`,
+		`</items?cursor=a&per_page=20&q=x>; rel="prev"`,
+		`</items?cursor=c&per_page=20&q=x>; rel="next"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link = %s, want it to contain %s", link, want)
+		}
+	}
+	if !strings.Contains(w.Body.String(), `"next_cursor": "c"`) {
+		t.Errorf("body = %s, want the next cursor in meta", w.Body)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	type position struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	cursor, err := EncodeCursor(position{7, "a/b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got position
+	if err := DecodeCursor(cursor, &got); err != nil || got != (position{7, "a/b"}) {
+		t.Errorf("DecodeCursor = %+v, %v", got, err)
+	}
+	for _, bad := range []string{"!!", "bm90IGpzb24"} {
+		if err := DecodeCursor(bad, &got); err == nil {
+			t.Errorf("DecodeCursor(%q) succeeded", bad)
+		}
+	}
+}
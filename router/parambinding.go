@@ -0,0 +1,231 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// bindSources are the struct tags BindRequest reads, in the order they are
+// applied.
+var bindSources = []string{"path", "query", "header", "cookie"}
+
+// BindError reports a request value that could not be stored in a field.
+type BindError struct {
+	// Source is where the value came from: "path", "query", "header",
+	// "cookie" or "form".
+	Source string
+	// Name is the parameter, header, cookie or form key.
+	Name string
+	// Field is the Go path of the struct field, such as "Filter.Limit".
+	Field string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("%s parameter %q: %v", e.Source, e.Name, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// BindErrors collects every BindError of one binding.
+type BindErrors []*BindError
+
+func (e BindErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// StatusCode returns 400 Bad Request.
+func (e BindErrors) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// BindRequest fills v, a pointer to a struct, from every part of the
+// request. The body is decoded with Bind; fields tagged path, query, header
+// or cookie are then set from route parameters, the query string, request
+// headers and cookies:
+//
+//	type ListOrders struct {
+//		Customer int       `path:"id"`
+//		Limit    *int      `query:"limit"`
+//		Status   []string  `query:"status"` // ?status=a&status=b
+//		Since    time.Time `query:"since"`  // RFC 3339
+//		Tenant   string    `header:"X-Tenant"`
+//		Session  string    `cookie:"session"`
+//	}
+//
+// Values are converted to the field's type: strings, numbers, booleans,
+// time.Duration, encoding.TextUnmarshaler implementations such as time.Time,
+// pointers, and slices filled from repeated query parameters or
+// comma-separated header values. Embedded and tagless nested structs are
+// searched for tagged fields too. Fields whose value is absent are left
+// alone. Conversion failures are collected and returned together as
+// BindErrors.
+func BindRequest(r *http.Request, v interface{}, opts ...BindOption) error {
+	var o bindOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rv, err := bindTarget(v)
+	if err != nil {
+		return err
+	}
+
+	if err := bindBody(r, v); err != nil {
+		return err
+	}
+
+	var errs BindErrors
+	bindTaggedFields(r, rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if o.validate {
+		return validationResult(r, v)
+	}
+	return nil
+}
+
+// bindTarget returns the struct v points to.
+func bindTarget(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("bind: expected a pointer to a struct, got %T", v)
+	}
+	return rv.Elem(), nil
+}
+
+func bindTaggedFields(r *http.Request, v reflect.Value, path string, errs *BindErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		field := v.Field(i)
+		fieldPath := sf.Name
+		if path != "" {
+			fieldPath = path + "." + sf.Name
+		}
+
+		tagged := false
+		for _, source := range bindSources {
+			name, ok := sf.Tag.Lookup(source)
+			if !ok || name == "-" {
+				continue
+			}
+			tagged = true
+			if !sf.IsExported() {
+				continue
+			}
+			values := sourceValues(r, source, name)
+			if source == "header" && isSliceType(sf.Type) {
+				values = splitHeaderValues(values)
+			}
+			if len(values) == 0 {
+				continue
+			}
+			if err := setStrings(field, values); err != nil {
+				*errs = append(*errs, &BindError{Source: source, Name: name, Field: fieldPath, Err: err})
+			}
+		}
+		if tagged {
+			continue
+		}
+
+		// Look for tagged fields in embedded and nested structs.
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct || ft == timeType || !sf.IsExported() && !sf.Anonymous || !hasBindTags(ft) {
+			continue
+		}
+		if field.Kind() == reflect.Pointer {
+			if field.IsNil() {
+				if !field.CanSet() {
+					continue
+				}
+				field.Set(reflect.New(ft))
+			}
+			field = field.Elem()
+		}
+		bindTaggedFields(r, field, fieldPath, errs)
+	}
+}
+
+// hasBindTags reports whether struct type t, or a struct nested in it,
+// has a field tagged for BindRequest.
+func hasBindTags(t reflect.Type) bool {
+	return hasBindTagsVisited(t, map[reflect.Type]bool{})
+}
+
+func hasBindTagsVisited(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		for _, source := range bindSources {
+			if _, ok := sf.Tag.Lookup(source); ok {
+				return true
+			}
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && hasBindTagsVisited(ft, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceValues returns the values named name in one part of the request.
+func sourceValues(r *http.Request, source, name string) []string {
+	switch source {
+	case "path":
+		if value, ok := pathParams(r)[name]; ok {
+			return []string{value}
+		}
+	case "query":
+		return r.URL.Query()[name]
+	case "header":
+		return r.Header.Values(name)
+	case "cookie":
+		if cookie, err := r.Cookie(name); err == nil {
+			return []string{cookie.Value}
+		}
+	}
+	return nil
+}
+
+// splitHeaderValues splits comma-separated header lines into their elements.
+func splitHeaderValues(lines []string) []string {
+	var values []string
+	for _, line := range lines {
+		for _, value := range strings.Split(line, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// isSliceType reports whether setStrings stores every value in a field of
+// type t.
+func isSliceType(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
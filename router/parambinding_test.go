@@ -0,0 +1,147 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type paramPaging struct {
+	Limit  *int `query:"limit"`
+	Offset uint `query:"offset"`
+}
+
+type paramFilter struct {
+	Status []string `query:"status"`
+}
+
+type listOrdersRequest struct {
+	Customer int           `path:"id"`
+	Since    time.Time     `query:"since"`
+	Timeout  time.Duration `query:"timeout"`
+	Ratio    float32       `query:"ratio"`
+	Active   bool          `query:"active"`
+	Tenant   string        `header:"X-Tenant"`
+	Accept   []string      `header:"Accept"`
+	Addr     net.IP        `header:"X-Addr"`
+	Session  string        `cookie:"session"`
+	Ignored  string        `query:"-"`
+	hidden   string        `query:"hidden"`
+	Body     string        `json:"body"`
+	paramPaging
+	Filter *paramFilter
+	Nested struct {
+		Debug bool `header:"X-Debug"`
+	}
+}
+
+func paramRequest(method, target, body string, params map[string]string) *http.Request {
+	var r *http.Request
+	if body != "" {
+		r = httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+	} else {
+		r = httptest.NewRequest(method, target, nil)
+	}
+	return r.WithContext(context.WithValue(r.Context(), paramsKey, params))
+}
+
+func TestBindRequest(t *testing.T) {
+	r := paramRequest("POST", "/customers/7/orders?since=2024-01-02T03:04:05Z&timeout=1m30s&ratio=0.5&active=true&limit=20&offset=40&status=open&status=paid&Ignored=x&hidden=x", `{"body":"hi"}`, map[string]string{"id": "7"})
+	r.Header.Set("X-Tenant", "acme")
+	r.Header.Add("Accept", "text/html, application/json")
+	r.Header.Add("Accept", "*/*")
+	r.Header.Set("X-Addr", "10.0.0.1")
+	r.Header.Set("X-Debug", "1")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "s3"})
+
+	var got listOrdersRequest
+	if err := BindRequest(r, &got); err != nil {
+		t.Fatal(err)
+	}
+	limit := 20
+	want := listOrdersRequest{
+		Customer:    7,
+		Since:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Timeout:     90 * time.Second,
+		Ratio:       0.5,
+		Active:      true,
+		Tenant:      "acme",
+		Accept:      []string{"text/html", "application/json", "*/*"},
+		Addr:        net.ParseIP("10.0.0.1"),
+		Session:     "s3",
+		Body:        "hi",
+		paramPaging: paramPaging{Limit: &limit, Offset: 40},
+		Filter:      &paramFilter{Status: []string{"open", "paid"}},
+	}
+	want.Nested.Debug = true
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bound\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestBindRequestAbsentValues(t *testing.T) {
+	limit := 5
+	got := listOrdersRequest{Tenant: "kept", paramPaging: paramPaging{Limit: &limit}}
+	if err := BindRequest(paramRequest("GET", "/", "", nil), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Tenant != "kept" || got.Limit != &limit || *got.Limit != 5 || got.Filter == nil {
+		t.Errorf("absent values changed fields: %+v", got)
+	}
+}
+
+func TestBindRequestErrors(t *testing.T) {
+	r := paramRequest("GET", "/?since=yesterday&limit=many&offset=-1&active=maybe&ratio=x&timeout=5", "", map[string]string{"id": "seven"})
+	r.Header.Set("X-Addr", "not-an-ip")
+	err := BindRequest(r, &listOrdersRequest{})
+
+	var errs BindErrors
+	if !errors.As(err, &errs) || errs.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("error = %v, want BindErrors", err)
+	}
+	want := map[string]string{
+		"path id":       "Customer",
+		"query since":   "Since",
+		"query timeout": "Timeout",
+		"query ratio":   "Ratio",
+		"query active":  "Active",
+		"header X-Addr": "Addr",
+		"query limit":   "paramPaging.Limit",
+		"query offset":  "paramPaging.Offset",
+	}
+	if len(errs) != len(want) {
+		t.Errorf("got %d errors, want %d: %v", len(errs), len(want), err)
+	}
+	for _, e := range errs {
+		if field, ok := want[e.Source+" "+e.Name]; !ok || field != e.Field || e.Err == nil {
+			t.Errorf("unexpected error %+v", e)
+		}
+	}
+
+	for _, v := range []interface{}{listOrdersRequest{}, (*listOrdersRequest)(nil), new(int)} {
+		if err := BindRequest(paramRequest("GET", "/", "", nil), v); err == nil {
+			t.Errorf("BindRequest into %T succeeded", v)
+		}
+	}
+}
+
+func TestBindRequestWithValidation(t *testing.T) {
+	type search struct {
+		Query string `query:"q" json:"q" validate:"required"`
+	}
+	err := BindRequest(paramRequest("GET", "/", "", nil), &search{}, WithValidation())
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || invalid.Errors[0].Field != "q" {
+		t.Errorf("error = %v, want a *ValidationError for q", err)
+	}
+	if err := BindRequest(paramRequest("GET", "/?q=go", "", nil), &search{}, WithValidation()); err != nil {
+		t.Errorf("valid request: %v", err)
+	}
+}
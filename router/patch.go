@@ -0,0 +1,589 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types of patch documents.
+const (
+	JSONPatchType  = "application/json-patch+json"
+	MergePatchType = "application/merge-patch+json"
+)
+
+// ErrPatchTestFailed is wrapped by the *PatchError of a failed JSON Patch
+// test operation.
+var ErrPatchTestFailed = errors.New("test failed")
+
+// PatchError reports a JSON Patch operation that could not be applied.
+type PatchError struct {
+	// Index is the position of the operation in the patch document.
+	Index int
+	Op    string
+	Path  string
+	Err   error
+	// status is 400 for malformed operations, 409 for failed tests and 422
+	// for operations that do not fit the document.
+	status int
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("patch operation %d (%s %q): %v", e.Index, e.Op, e.Path, e.Err)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns 400 Bad Request for a malformed operation, 409 Conflict
+// for a failed test and 422 Unprocessable Content otherwise, as RFC 5789
+// suggests.
+func (e *PatchError) StatusCode() int {
+	return e.status
+}
+
+func init() {
+	RegisterBinder(JSONPatchType, BinderFunc(BindPatch))
+	RegisterBinder(MergePatchType, BinderFunc(BindPatch))
+}
+
+// BindPatch applies the patch in the request body to v, a pointer to a
+// struct holding the resource's current state. The patch is a JSON Patch
+// (RFC 6902) or a JSON Merge Patch (RFC 7396), chosen by the request's
+// Content-Type, and works on the JSON form of v. The patched result is
+// checked with ValidateStruct; v is only updated when the patch applies and
+// the result is valid. Bind calls BindPatch for both media types.
+//
+//	order := loadOrder(router.Param(r, "id"))
+//	if err := router.BindPatch(r, order); err != nil {
+//		...
+//	}
+//	saveOrder(order)
+func BindPatch(r *http.Request, v interface{}) error {
+	rv, err := bindTarget(v)
+	if err != nil {
+		return err
+	}
+
+	doc, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	patched, err := ApplyPatch(r, doc)
+	if err != nil {
+		return err
+	}
+
+	// Decode into a copy of v whose JSON fields are cleared, so fields the
+	// JSON form leaves out, such as json:"-" and unexported ones, survive.
+	decoded, err := decodeJSONValue(patched)
+	if err != nil {
+		return err
+	}
+	object, _ := decoded.(map[string]interface{})
+	result := reflect.New(rv.Type())
+	result.Elem().Set(rv)
+	clearJSONFields(result.Elem(), object)
+	if err := json.Unmarshal(patched, result.Interface()); err != nil {
+		return jsonError(patched, result.Interface(), err)
+	}
+	if err := validationResult(r, result.Interface()); err != nil {
+		return err
+	}
+	rv.Set(result.Elem())
+	return nil
+}
+
+// clearJSONFields prepares struct v, a copy of the value being patched, for
+// decoding the patched document doc: fields that appear in the JSON form are
+// zeroed and the others left as they are. Structs behind pointers that doc
+// still holds are copied and cleared the same way, so their hidden fields
+// survive without changing the original.
+func clearJSONFields(v reflect.Value, doc map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" || !sf.IsExported() && !sf.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		field := v.Field(i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// Embedded structs share their parent's object.
+			if sf.Type.Kind() == reflect.Pointer {
+				if !field.IsNil() && field.CanSet() {
+					c := reflect.New(ft)
+					c.Elem().Set(field.Elem())
+					clearJSONFields(c.Elem(), doc)
+					field.Set(c)
+				}
+				continue
+			}
+			clearJSONFields(field, doc)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		clearJSONValue(field, objectMember(doc, name))
+	}
+}
+
+func clearJSONValue(v reflect.Value, doc interface{}) {
+	t := v.Type()
+	object, _ := doc.(map[string]interface{})
+	switch {
+	case t.Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(jsonUnmarshalerType):
+	case t.Kind() == reflect.Struct:
+		clearJSONFields(v, object)
+		return
+	case t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct && !v.IsNil() && object != nil && v.CanSet():
+		c := reflect.New(t.Elem())
+		c.Elem().Set(v.Elem())
+		clearJSONFields(c.Elem(), object)
+		v.Set(c)
+		return
+	}
+	if v.CanSet() {
+		v.Set(reflect.Zero(t))
+	}
+}
+
+// objectMember returns the member of object that encoding/json would store in
+// a field named name.
+func objectMember(object map[string]interface{}, name string) interface{} {
+	if value, ok := object[name]; ok {
+		return value
+	}
+	for key, value := range object {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+// ApplyPatch applies the JSON Patch or JSON Merge Patch in the request body
+// to the JSON document doc, as chosen by the request's Content-Type, and
+// returns the patched document. Other media types yield an
+// *UnsupportedMediaTypeError.
+func ApplyPatch(r *http.Request, doc []byte) ([]byte, error) {
+	supported := []string{JSONPatchType, MergePatchType}
+	contentType := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, &UnsupportedMediaTypeError{ContentType: contentType, Supported: supported}
+	}
+	if mediaType != JSONPatchType && mediaType != MergePatchType {
+		return nil, &UnsupportedMediaTypeError{ContentType: mediaType, Supported: supported}
+	}
+
+	if r.Body == nil {
+		return nil, errors.New("request body is empty")
+	}
+	patch, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return nil, &RequestTooLargeError{Limit: maxBytes.Limit}
+		}
+		return nil, err
+	}
+
+	if mediaType == JSONPatchType {
+		return ApplyJSONPatch(doc, patch)
+	}
+	return ApplyMergePatch(doc, patch)
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON Patch to the JSON document doc.
+// Operations are applied in order and the first failure, reported as a
+// *PatchError, abandons the patch.
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodeJSONValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	var ops []map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, jsonError(patch, &ops, err)
+	}
+
+	for i, op := range ops {
+		var perr *PatchError
+		if target, perr = applyPatchOp(target, op); perr != nil {
+			perr.Index = i
+			return nil, perr
+		}
+	}
+	return json.Marshal(target)
+}
+
+// ApplyMergePatch applies an RFC 7396 JSON Merge Patch to the JSON document
+// doc: members of patch objects replace those of doc, recursively, and null
+// members remove them.
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decodeJSONValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	merge, err := decodeJSONValue(patch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(target, merge))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	members, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+	for name, value := range members {
+		if value == nil {
+			delete(object, name)
+		} else {
+			object[name] = mergePatch(object[name], value)
+		}
+	}
+	return object
+}
+
+// decodeJSONValue decodes data into maps, slices and json.Numbers.
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, jsonError(data, &v, err)
+	}
+	return v, nil
+}
+
+// applyPatchOp applies one JSON Patch operation to doc and returns the new
+// document.
+func applyPatchOp(doc interface{}, op map[string]json.RawMessage) (interface{}, *PatchError) {
+	var name, path, from string
+	perr := &PatchError{status: http.StatusBadRequest}
+	if err := patchMember(op, "op", &name); err != nil {
+		perr.Err = err
+		return nil, perr
+	}
+	perr.Op = name
+	if err := patchMember(op, "path", &path); err != nil {
+		perr.Err = err
+		return nil, perr
+	}
+	perr.Path = path
+	tokens, err := parsePointer(path)
+	if err != nil {
+		perr.Err = err
+		return nil, perr
+	}
+
+	var value interface{}
+	switch name {
+	case "add", "replace", "test":
+		raw, ok := op["value"]
+		if !ok {
+			perr.Err = errors.New(`missing "value"`)
+			return nil, perr
+		}
+		if value, err = decodeJSONValue(raw); err != nil {
+			perr.Err = err
+			return nil, perr
+		}
+	case "move", "copy":
+		if err := patchMember(op, "from", &from); err != nil {
+			perr.Err = err
+			return nil, perr
+		}
+	case "remove":
+	default:
+		perr.Err = fmt.Errorf("unknown operation %q", name)
+		return nil, perr
+	}
+
+	perr.status = http.StatusUnprocessableEntity
+	switch name {
+	case "add":
+		doc, err = pointerAdd(doc, tokens, value)
+	case "remove":
+		doc, _, err = pointerRemove(doc, tokens)
+	case "replace":
+		doc, err = pointerReplace(doc, tokens, value)
+	case "move":
+		var fromTokens []string
+		if fromTokens, err = parsePointer(from); err != nil {
+			break
+		}
+		if from != path && strings.HasPrefix(path, from+"/") {
+			err = fmt.Errorf("cannot move %q into itself", from)
+			break
+		}
+		if doc, value, err = pointerRemove(doc, fromTokens); err == nil {
+			doc, err = pointerAdd(doc, tokens, value)
+		}
+	case "copy":
+		var fromTokens []string
+		if fromTokens, err = parsePointer(from); err != nil {
+			break
+		}
+		if value, err = pointerGet(doc, fromTokens); err == nil {
+			doc, err = pointerAdd(doc, tokens, copyJSONValue(value))
+		}
+	case "test":
+		var current interface{}
+		if current, err = pointerGet(doc, tokens); err == nil && !equalJSONValues(current, value) {
+			perr.status = http.StatusConflict
+			err = ErrPatchTestFailed
+		}
+	}
+	if err != nil {
+		perr.Err = err
+		return nil, perr
+	}
+	return doc, nil
+}
+
+func patchMember(op map[string]json.RawMessage, name string, s *string) error {
+	raw, ok := op[name]
+	if !ok {
+		return fmt.Errorf("missing %q", name)
+	}
+	if err := json.Unmarshal(raw, s); err != nil {
+		return fmt.Errorf("%q must be a string", name)
+	}
+	return nil
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+	return tokens, nil
+}
+
+func pointerGet(doc interface{}, tokens []string) (interface{}, error) {
+	for _, token := range tokens {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			doc = value
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("cannot find %q in a scalar value", token)
+		}
+	}
+	return doc, nil
+}
+
+// pointerAdd adds value at tokens, which may name a new object member or an
+// array position ("-" appends), and returns the new document.
+func pointerAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+			return node, nil
+		case []interface{}:
+			if token == "-" {
+				return append(node, value), nil
+			}
+			i, err := arrayIndex(token, len(node))
+			if err != nil {
+				return nil, err
+			}
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+		return nil, fmt.Errorf("cannot add %q to a scalar value", token)
+	})
+}
+
+// pointerReplace replaces the existing value at tokens and returns the new
+// document.
+func pointerReplace(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if _, err := pointerGet(doc, tokens); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			node[token] = value
+		case []interface{}:
+			i, _ := arrayIndex(token, len(node)-1)
+			node[i] = value
+		}
+		return parent, nil
+	})
+}
+
+// pointerRemove removes the value at tokens and returns the new document
+// and the removed value.
+func pointerRemove(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	var removed interface{}
+	doc, err := pointerUpdate(doc, tokens, func(parent interface{}, token string) (interface{}, error) {
+		switch node := parent.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return append(node[:i], node[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("cannot remove %q from a scalar value", token)
+	})
+	return doc, removed, err
+}
+
+// pointerUpdate replaces the container holding the last of tokens with the
+// result of fn, rebuilding the path to it since arrays may be reallocated.
+func pointerUpdate(doc interface{}, tokens []string, fn func(parent interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0])
+	}
+	child, err := pointerGet(doc, tokens[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = pointerUpdate(child, tokens[1:], fn); err != nil {
+		return nil, err
+	}
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		node[tokens[0]] = child
+	case []interface{}:
+		i, _ := arrayIndex(tokens[0], len(node)-1)
+		node[i] = child
+	}
+	return doc, nil
+}
+
+// arrayIndex parses an array index token no greater than max.
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d is out of range", i)
+	}
+	return i, nil
+}
+
+func copyJSONValue(v interface{}) interface{} {
+	switch node := v.(type) {
+	case map[string]interface{}:
+		object := make(map[string]interface{}, len(node))
+		for name, value := range node {
+			object[name] = copyJSONValue(value)
+		}
+		return object
+	case []interface{}:
+		array := make([]interface{}, len(node))
+		for i, value := range node {
+			array[i] = copyJSONValue(value)
+		}
+		return array
+	}
+	return v
+}
+
+// equalJSONValues compares decoded JSON values, treating numbers as equal
+// when their values are.
+func equalJSONValues(a, b interface{}) bool {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for name, value := range x {
+			other, ok := y[name]
+			if !ok || !equalJSONValues(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equalJSONValues(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if x == y {
+			return true
+		}
+		fx, errx := x.Float64()
+		fy, erry := y.Float64()
+		return errx == nil && erry == nil && fx == fy
+	}
+	return a == b
+}
@@ -0,0 +1,193 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyJSONPatch(t *testing.T) {
+	doc := `{"name":"a","tags":["x","y"],"address":{"city":"Oslo"}}`
+	tests := []struct {
+		patch string
+		want  string
+	}{
+		{`[]`, doc},
+		{`[{"op":"add","path":"/qty","value":2}]`, `{"name":"a","qty":2,"tags":["x","y"],"address":{"city":"Oslo"}}`},
+		{`[{"op":"add","path":"/tags/1","value":"z"}]`, `{"name":"a","tags":["x","z","y"],"address":{"city":"Oslo"}}`},
+		{`[{"op":"add","path":"/tags/-","value":"z"}]`, `{"name":"a","tags":["x","y","z"],"address":{"city":"Oslo"}}`},
+		{`[{"op":"remove","path":"/tags/0"}]`, `{"name":"a","tags":["y"],"address":{"city":"Oslo"}}`},
+		{`[{"op":"replace","path":"/address/city","value":"Rome"}]`, `{"name":"a","tags":["x","y"],"address":{"city":"Rome"}}`},
+		{`[{"op":"move","from":"/address/city","path":"/city"}]`, `{"name":"a","tags":["x","y"],"address":{},"city":"Oslo"}`},
+		{`[{"op":"copy","from":"/name","path":"/alias"}]`, `{"name":"a","alias":"a","tags":["x","y"],"address":{"city":"Oslo"}}`},
+		{`[{"op":"test","path":"/tags","value":["x","y"]},{"op":"remove","path":"/tags"}]`, `{"name":"a","address":{"city":"Oslo"}}`},
+		{`[{"op":"replace","path":"","value":[1]}]`, `[1]`},
+	}
+
+	for _, tt := range tests {
+		got, err := ApplyJSONPatch([]byte(doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("%s: %v", tt.patch, err)
+			continue
+		}
+		if !jsonEqual(t, got, []byte(tt.want)) {
+			t.Errorf("%s: patched to %s, want %s", tt.patch, got, tt.want)
+		}
+	}
+}
+
+func TestApplyJSONPatchErrors(t *testing.T) {
+	doc := `{"name":"a","tags":["x"]}`
+	tests := []struct {
+		patch  string
+		index  int
+		status int
+	}{
+		{`[{"op":"jump","path":"/name"}]`, 0, http.StatusBadRequest},
+		{`[{"op":"add","path":"/name"}]`, 0, http.StatusBadRequest},
+		{`[{"op":"add","path":"name","value":1}]`, 0, http.StatusBadRequest},
+		{`[{"op":"add","path":"/a","value":1},{"op":"test","path":"/name","value":"b"}]`, 1, http.StatusConflict},
+		{`[{"op":"remove","path":"/missing"}]`, 0, http.StatusUnprocessableEntity},
+		{`[{"op":"add","path":"/tags/5","value":"z"}]`, 0, http.StatusUnprocessableEntity},
+		{`[{"op":"move","from":"/tags","path":"/tags/0"}]`, 0, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		_, err := ApplyJSONPatch([]byte(doc), []byte(tt.patch))
+		var perr *PatchError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: error = %v, want *PatchError", tt.patch, err)
+			continue
+		}
+		if perr.Index != tt.index || perr.StatusCode() != tt.status {
+			t.Errorf("%s: operation %d with status %d, want %d with %d", tt.patch, perr.Index, perr.StatusCode(), tt.index, tt.status)
+		}
+		if tt.status == http.StatusConflict && !errors.Is(err, ErrPatchTestFailed) {
+			t.Errorf("%s: error %v does not wrap ErrPatchTestFailed", tt.patch, err)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":1}}`, `{"a":{"b":"c","f":1}}`},
+		{`{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`["a"]`, `{"a":"b"}`, `{"a":"b"}`},
+	}
+
+	for _, tt := range tests {
+		got, err := ApplyMergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Errorf("%s + %s: %v", tt.doc, tt.patch, err)
+			continue
+		}
+		if !jsonEqual(t, got, []byte(tt.want)) {
+			t.Errorf("%s + %s = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
+
+type patchAddress struct {
+	City string `json:"city"`
+	note string
+}
+
+type patchUser struct {
+	Name         string            `json:"name" validate:"required"`
+	Attrs        map[string]string `json:"attrs,omitempty"`
+	Address      *patchAddress     `json:"address,omitempty"`
+	PasswordHash string            `json:"-"`
+	tenant       string
+}
+
+func patchRequest(contentType, body string) *http.Request {
+	r := httptest.NewRequest("PATCH", "/", strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	return r
+}
+
+func TestBindPatch(t *testing.T) {
+	original := func() patchUser {
+		return patchUser{
+			Name:         "a",
+			Attrs:        map[string]string{"color": "red", "size": "L"},
+			Address:      &patchAddress{City: "Oslo", note: "gate code"},
+			PasswordHash: "hash",
+			tenant:       "acme",
+		}
+	}
+	tests := []struct {
+		contentType, body string
+		want              func(*patchUser)
+	}{
+		{MergePatchType, `{"name":"b"}`, func(u *patchUser) { u.Name = "b" }},
+		{MergePatchType, `{"attrs":{"size":null}}`, func(u *patchUser) { u.Attrs = map[string]string{"color": "red"} }},
+		{MergePatchType, `{"address":{"city":"Rome"}}`, func(u *patchUser) { u.Address = &patchAddress{City: "Rome", note: "gate code"} }},
+		{MergePatchType, `{"address":null}`, func(u *patchUser) { u.Address = nil }},
+		{JSONPatchType, `[{"op":"remove","path":"/attrs/color"}]`, func(u *patchUser) { u.Attrs = map[string]string{"size": "L"} }},
+		{JSONPatchType, `[{"op":"replace","path":"/name","value":"c"}]`, func(u *patchUser) { u.Name = "c" }},
+	}
+
+	for _, tt := range tests {
+		got := original()
+		address := got.Address
+		if err := BindPatch(patchRequest(tt.contentType, tt.body), &got); err != nil {
+			t.Errorf("%s: %v", tt.body, err)
+			continue
+		}
+		want := original()
+		tt.want(&want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: patched to %+v, want %+v", tt.body, got, want)
+		}
+		if *address != *original().Address {
+			t.Errorf("%s: the original address was changed to %+v", tt.body, *address)
+		}
+	}
+}
+
+func TestBindPatchRejected(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+		status            int
+	}{
+		{MergePatchType, `{"name":""}`, http.StatusUnprocessableEntity},
+		{MergePatchType, `{"name":1}`, http.StatusBadRequest},
+		{JSONPatchType, `[{"op":"test","path":"/name","value":"z"}]`, http.StatusConflict},
+		{"application/json", `{"name":"b"}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		u := patchUser{Name: "a", PasswordHash: "hash"}
+		err := BindPatch(patchRequest(tt.contentType, tt.body), &u)
+		var coded interface{ StatusCode() int }
+		if !errors.As(err, &coded) || coded.StatusCode() != tt.status {
+			t.Errorf("%s: error = %v, want status %d", tt.body, err, tt.status)
+		}
+		if u.Name != "a" || u.PasswordHash != "hash" {
+			t.Errorf("%s: target changed to %+v", tt.body, u)
+		}
+	}
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,50 @@
+package router
+
+import (
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Problem is an RFC 9457 problem details object.
+type Problem struct {
+	XMLName  xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type     string   `json:"type,omitempty" xml:"type,omitempty"`
+	Title    string   `json:"title,omitempty" xml:"title,omitempty"`
+	Status   int      `json:"status,omitempty" xml:"status,omitempty"`
+	Detail   string   `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string   `json:"instance,omitempty" xml:"instance,omitempty"`
+}
+
+// NewProblem creates a problem for status. Its title is the status text and
+// its detail the given text, both translated into the language of r with T.
+func NewProblem(r *http.Request, status int, detail string) *Problem {
+	p := &Problem{
+		Title:  T(r, http.StatusText(status)),
+		Status: status,
+	}
+	if detail != "" {
+		p.Detail = T(r, detail)
+	}
+	return p
+}
+
+// RespondProblem writes problem, usually a *Problem or a struct embedding
+// one, as application/problem+json or, for clients that prefer XML,
+// application/problem+xml. Content-Language is set to the language of r.
+func RespondProblem(w http.ResponseWriter, r *http.Request, status int, problem interface{}) {
+	addVary(w.Header(), "Accept")
+	contentType, enc := "application/problem+json", JSONEncoder
+	offers := []string{"application/problem+json", "application/json", "application/problem+xml", "application/xml"}
+	if strings.HasSuffix(NegotiateContentType(r, offers), "xml") {
+		contentType, enc = "application/problem+xml", XMLEncoder
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Language", Language(r))
+	w.WriteHeader(status)
+	if err := enc.Encode(w, problem); err != nil {
+		log.Printf("respond: encoding %s: %v", contentType, err)
+	}
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNewProblem(t *testing.T) {
+	withFrench(t)
+	tests := []struct {
+		language string
+		detail   string
+		want     Problem
+	}{
+		{"", "", Problem{Title: "Not Found", Status: 404}},
+		{"fr", "", Problem{Title: "Introuvable", Status: 404}},
+		{"fr", "No such item", Problem{Title: "Introuvable", Status: 404, Detail: "No such item"}},
+	}
+
+	for _, tt := range tests {
+		if got := NewProblem(languageRequest(tt.language), http.StatusNotFound, tt.detail); *got != tt.want {
+			t.Errorf("%q, %q: NewProblem = %+v, want %+v", tt.language, tt.detail, *got, tt.want)
+		}
+	}
+}
+
+func TestRespondProblem(t *testing.T) {
+	withFrench(t)
+	problem := &Problem{Type: "https://example.com/out-of-stock", Title: "Out of stock", Status: 409}
+	tests := []struct {
+		accept      string
+		language    string
+		contentType string
+		body        string
+	}{
+		{"", "", "application/problem+json", `"type": "https://example.com/out-of-stock"`},
+		{"application/json", "fr", "application/problem+json", `"status": 409`},
+		{"application/problem+xml", "", "application/problem+xml", `<problem xmlns="urn:ietf:rfc:7807">`},
+		{"text/xml, application/xml;q=0.9", "", "application/problem+xml", `<status>409</status>`},
+	}
+
+	for _, tt := range tests {
+		r := languageRequest(tt.language)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		RespondProblem(w, r, http.StatusConflict, problem)
+
+		wantLanguage := "en"
+		if tt.language != "" {
+			wantLanguage = tt.language
+		}
+		if w.Code != http.StatusConflict || w.Header().Get("Content-Type") != tt.contentType ||
+			w.Header().Get("Content-Language") != wantLanguage || !strings.Contains(w.Header().Get("Vary"), "Accept") {
+			t.Errorf("Accept %q: status %d, headers %v", tt.accept, w.Code, w.Header())
+		}
+		if !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("Accept %q: body %s, want it to contain %s", tt.accept, w.Body, tt.body)
+		}
+	}
+}
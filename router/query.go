@@ -0,0 +1,264 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FilterOp is a comparison in a filter expression.
+type FilterOp string
+
+const (
+	OpEq       FilterOp = "eq"
+	OpNe       FilterOp = "ne"
+	OpGt       FilterOp = "gt"
+	OpGte      FilterOp = "gte"
+	OpLt       FilterOp = "lt"
+	OpLte      FilterOp = "lte"
+	OpIn       FilterOp = "in"
+	OpContains FilterOp = "contains"
+)
+
+var filterOps = []FilterOp{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn, OpContains}
+
+// Filter is one filter[field][op]=value expression.
+type Filter struct {
+	Field string
+	Op    FilterOp
+	// Value holds the parsed value, typed like the struct field it filters.
+	// For OpIn it is a slice of that type.
+	Value interface{}
+}
+
+// SortField is one entry of the sort parameter.
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// Query is the parsed form of a list endpoint's filter, sort and fields
+// parameters.
+type Query struct {
+	Filters []Filter
+	Sort    []SortField
+	// Fields is the sparse fieldset requested with fields=, or nil for all
+	// fields. Pass it to Respond with WithFields.
+	Fields []string
+}
+
+// QueryError reports an invalid or disallowed query parameter.
+type QueryError struct {
+	Param   string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query parameter %q: %s", e.Param, e.Message)
+}
+
+// queryField is a struct field as seen by ParseQuery.
+type queryField struct {
+	typ      reflect.Type
+	ops      map[FilterOp]bool
+	sortable bool
+}
+
+// ParseQuery parses the filter, sort and fields parameters of r against the
+// struct model, given as a value, a pointer or a slice of either. Fields are
+// named by their json tags. The queryable tag whitelists what clients may do
+// with a field:
+//
+//	Status string    `json:"status" queryable:"filter,sort"`
+//	Age    int       `json:"age" queryable:"eq,gt,lt"`
+//	Joined time.Time `json:"joined" queryable:"sort"`
+//
+// "filter" allows every operator that suits the field's type; operators can
+// also be listed one by one. Every field may be requested in fields, but
+// only whitelisted ones can be filtered or sorted. Violations are reported
+// as a *QueryError.
+//
+// Filters are written filter[field]=value, meaning eq, or
+// filter[field][op]=value; the in operator takes a comma-separated list.
+// Sort takes a comma-separated list of fields, each prefixed with "-" for
+// descending order.
+func ParseQuery(r *http.Request, model interface{}) (*Query, error) {
+	t := reflect.TypeOf(model)
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("query: model must be a struct, got %T", model)
+	}
+	fields := queryFields(t)
+	values := r.URL.Query()
+	q := &Query{}
+
+	// Sort the keys so errors and filters come out in a stable order.
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, "filter[") {
+			continue
+		}
+		for _, value := range values[key] {
+			filter, err := parseFilter(key, value, fields)
+			if err != nil {
+				return nil, err
+			}
+			q.Filters = append(q.Filters, filter)
+		}
+	}
+
+	if value := values.Get("sort"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			desc := strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			if f, ok := fields[name]; !ok || !f.sortable {
+				return nil, &QueryError{Param: "sort", Message: fmt.Sprintf("cannot sort by %q", name)}
+			}
+			q.Sort = append(q.Sort, SortField{Field: name, Desc: desc})
+		}
+	}
+
+	if _, ok := values["fields"]; ok {
+		q.Fields = []string{}
+		for _, name := range strings.Split(values.Get("fields"), ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if _, ok := fields[name]; !ok {
+				return nil, &QueryError{Param: "fields", Message: fmt.Sprintf("unknown field %q", name)}
+			}
+			q.Fields = append(q.Fields, name)
+		}
+	}
+
+	return q, nil
+}
+
+func parseFilter(key, value string, fields map[string]queryField) (Filter, error) {
+	// key is filter[field] or filter[field][op].
+	name, rest, ok := strings.Cut(strings.TrimPrefix(key, "filter["), "]")
+	op := OpEq
+	if ok && rest != "" {
+		opName, opened := strings.CutPrefix(rest, "[")
+		opName, closed := strings.CutSuffix(opName, "]")
+		ok = opened && closed
+		op = FilterOp(opName)
+	}
+	if !ok || name == "" {
+		return Filter{}, &QueryError{Param: key, Message: "malformed filter"}
+	}
+
+	f, exists := fields[name]
+	if !exists || len(f.ops) == 0 {
+		return Filter{}, &QueryError{Param: key, Message: fmt.Sprintf("cannot filter by %q", name)}
+	}
+	if !f.ops[op] {
+		return Filter{}, &QueryError{Param: key, Message: fmt.Sprintf("operator %q is not allowed on %q", op, name)}
+	}
+
+	filter := Filter{Field: name, Op: op}
+	if op == OpIn {
+		parts := strings.Split(value, ",")
+		list := reflect.MakeSlice(reflect.SliceOf(f.typ), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setString(list.Index(i), strings.TrimSpace(part)); err != nil {
+				return Filter{}, &QueryError{Param: key, Message: err.Error()}
+			}
+		}
+		filter.Value = list.Interface()
+		return filter, nil
+	}
+
+	parsed, err := parseString(f.typ, value)
+	if err != nil {
+		return Filter{}, &QueryError{Param: key, Message: err.Error()}
+	}
+	filter.Value = parsed
+	return filter, nil
+}
+
+// queryFields maps the json names of t's fields to what the queryable tag
+// allows on them.
+func queryFields(t reflect.Type) map[string]queryField {
+	fields := map[string]queryField{}
+	for _, sf := range structFields(t, "json") {
+		typ := sf.typ
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		f := queryField{typ: typ, ops: map[FilterOp]bool{}}
+
+		tag := reflect.StructTag("")
+		if parent := fieldType(t, sf.index); parent != nil {
+			tag = parent.Tag
+		}
+		allowed := filterOpsFor(typ)
+		for _, opt := range strings.Split(tag.Get("queryable"), ",") {
+			switch opt = strings.TrimSpace(opt); opt {
+			case "":
+			case "sort":
+				f.sortable = true
+			case "filter":
+				for _, op := range allowed {
+					f.ops[op] = true
+				}
+			default:
+				for _, op := range allowed {
+					if string(op) == opt {
+						f.ops[op] = true
+					}
+				}
+			}
+		}
+		fields[sf.name] = f
+	}
+	return fields
+}
+
+// fieldType returns the struct field of t at index, following embedded
+// pointers.
+func fieldType(t reflect.Type, index []int) *reflect.StructField {
+	for i, x := range index {
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		sf := t.Field(x)
+		if i == len(index)-1 {
+			return &sf
+		}
+		t = sf.Type
+	}
+	return nil
+}
+
+// filterOpsFor lists the operators that make sense for values of type t.
+func filterOpsFor(t reflect.Type) []FilterOp {
+	switch {
+	case t == timeType || t == durationType:
+		return []FilterOp{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return []FilterOp{OpEq, OpNe, OpIn}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return filterOps
+	case reflect.Bool:
+		return []FilterOp{OpEq, OpNe}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return []FilterOp{OpEq, OpNe, OpGt, OpGte, OpLt, OpLte, OpIn}
+	}
+	return nil
+}
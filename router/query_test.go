@@ -0,0 +1,104 @@
+package router
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type queryAudit struct {
+	Created time.Time `json:"created" queryable:"gt,lt,sort"`
+}
+
+type queryOrder struct {
+	ID     int      `json:"id" queryable:"filter,sort"`
+	Status string   `json:"status" queryable:"filter"`
+	Total  *float64 `json:"total" queryable:"gte,lte"`
+	Paid   bool     `json:"paid" queryable:"filter,contains"`
+	Note   string   `json:"note"`
+	Secret string   `json:"-" queryable:"filter"`
+	queryAudit
+}
+
+func TestParseQuery(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		query string
+		want  Query
+	}{
+		{"", Query{}},
+		{
+			"filter[status]=open&filter[id][in]=1,%202&filter[total][gte]=9.5&filter[paid][ne]=true",
+			Query{Filters: []Filter{
+				{Field: "id", Op: OpIn, Value: []int{1, 2}},
+				{Field: "paid", Op: OpNe, Value: true},
+				{Field: "status", Op: OpEq, Value: "open"},
+				{Field: "total", Op: OpGte, Value: 9.5},
+			}},
+		},
+		{
+			"filter[status][contains]=pen&filter[status][ne]=x&filter[created][gt]=2024-01-02T00:00:00Z",
+			Query{Filters: []Filter{
+				{Field: "created", Op: OpGt, Value: day},
+				{Field: "status", Op: OpContains, Value: "pen"},
+				{Field: "status", Op: OpNe, Value: "x"},
+			}},
+		},
+		{"filter[id]=1&filter[id]=2", Query{Filters: []Filter{{Field: "id", Op: OpEq, Value: 1}, {Field: "id", Op: OpEq, Value: 2}}}},
+		{"sort=-created,%20id", Query{Sort: []SortField{{Field: "created", Desc: true}, {Field: "id"}}}},
+		{"fields=id,note,,status", Query{Fields: []string{"id", "note", "status"}}},
+		{"fields=", Query{Fields: []string{}}},
+		{"page=2&other=x", Query{}},
+	}
+
+	for _, tt := range tests {
+		q, err := ParseQuery(httptest.NewRequest("GET", "/orders?"+tt.query, nil), []queryOrder(nil))
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(*q, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.query, *q, tt.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		param string
+	}{
+		{"filter[note]=x", "filter[note]"},
+		{"filter[nope]=x", "filter[nope]"},
+		{"filter[Secret]=x", "filter[Secret]"},
+		{"filter[total]=1", "filter[total]"},
+		{"filter[paid][contains]=t", "filter[paid][contains]"},
+		{"filter[status][like]=x", "filter[status][like]"},
+		{"filter[id]=one", "filter[id]"},
+		{"filter[id][in]=1,x", "filter[id][in]"},
+		{"filter[created][gt]=yesterday", "filter[created][gt]"},
+		{"filter[id]x=1", "filter[id]x"},
+		{"filter[id][gt=1", "filter[id][gt"},
+		{"filter[]=1", "filter[]"},
+		{"sort=status", "sort"},
+		{"sort=-", "sort"},
+		{"fields=id,password", "fields"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/orders?"+tt.query, nil)
+		_, err := ParseQuery(r, &queryOrder{})
+		var qe *QueryError
+		if !errors.As(err, &qe) || qe.Param != tt.param {
+			t.Errorf("%s: error = %v, want a *QueryError for %s", tt.query, err, tt.param)
+		}
+	}
+
+	for _, model := range []interface{}{nil, 1, []string{}} {
+		if _, err := ParseQuery(httptest.NewRequest("GET", "/", nil), model); err == nil {
+			t.Errorf("ParseQuery with model %T succeeded", model)
+		}
+	}
+}
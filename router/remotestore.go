@@ -0,0 +1,425 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RemoteClient is the key/value API a RemoteStore needs from a cache server
+// shared between replicas.
+type RemoteClient interface {
+	// Get returns the value for key or ErrCacheMiss.
+	Get(key string) ([]byte, error)
+	// Set stores value under key. A positive ttl makes the server expire it.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes the given keys.
+	Delete(keys ...string) error
+	// Keys lists the keys starting with prefix.
+	Keys(prefix string) ([]string, error)
+}
+
+// RemoteStore is a CacheStore that keeps entries on a shared server through
+// a RemoteClient, so every replica sees the same cached responses. Each
+// entry is written twice: whole, and without its body as an index record
+// that DeleteFunc reads, so invalidation never downloads response bodies.
+type RemoteStore struct {
+	client RemoteClient
+	prefix string
+}
+
+// Namespaces of the keys a RemoteStore writes under its prefix.
+const (
+	remoteEntryPrefix = "entry:"
+	remoteIndexPrefix = "index:"
+)
+
+// NewRemoteStore creates a RemoteStore. prefix namespaces the keys it writes
+// so several caches can share one server.
+func NewRemoteStore(client RemoteClient, prefix string) *RemoteStore {
+	return &RemoteStore{client: client, prefix: prefix}
+}
+
+func (s *RemoteStore) Get(key string) (*CacheEntry, error) {
+	data, err := s.client.Get(s.prefix + remoteEntryPrefix + key)
+	if err != nil {
+		return nil, err
+	}
+	var entry CacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return nil, fmt.Errorf("remote store: %s: %v", key, err)
+	}
+	return &entry, nil
+}
+
+func (s *RemoteStore) Set(key string, entry *CacheEntry) error {
+	ttl := time.Until(entry.retainUntil())
+	if ttl <= 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(entry); err != nil {
+		return err
+	}
+	if err := s.client.Set(s.prefix+remoteEntryPrefix+key, buf.Bytes(), ttl); err != nil {
+		return err
+	}
+
+	record := *entry
+	record.Body = nil
+	buf.Reset()
+	if err := gob.NewEncoder(&buf).Encode(&record); err != nil {
+		return err
+	}
+	return s.client.Set(s.prefix+remoteIndexPrefix+key, buf.Bytes(), ttl)
+}
+
+func (s *RemoteStore) Delete(key string) error {
+	return s.client.Delete(s.prefix+remoteEntryPrefix+key, s.prefix+remoteIndexPrefix+key)
+}
+
+// DeleteFunc lists the index records and passes each to match without its
+// body.
+func (s *RemoteStore) DeleteFunc(match func(key string, entry *CacheEntry) bool) error {
+	indexPrefix := s.prefix + remoteIndexPrefix
+	keys, err := s.client.Keys(indexPrefix)
+	if err != nil {
+		return err
+	}
+
+	var doomed []string
+	for _, indexKey := range keys {
+		key := strings.TrimPrefix(indexKey, indexPrefix)
+		data, err := s.client.Get(indexKey)
+		if errors.Is(err, ErrCacheMiss) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		var entry CacheEntry
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+			return fmt.Errorf("remote store: %s: %v", key, err)
+		}
+		if match(key, &entry) {
+			doomed = append(doomed, s.prefix+remoteEntryPrefix+key, indexKey)
+		}
+	}
+	if len(doomed) == 0 {
+		return nil
+	}
+	return s.client.Delete(doomed...)
+}
+
+// RESPOptions configures a RESPClient.
+type RESPOptions struct {
+	// Addr is the host:port of the server.
+	Addr string
+	// Password is sent with AUTH when set.
+	Password string
+	// DB is selected with SELECT when non-zero.
+	DB int
+	// Timeout bounds dialing and each command. Defaults to 5 seconds.
+	Timeout time.Duration
+	// MaxIdle is the number of idle connections kept for reuse. Defaults to 4.
+	MaxIdle int
+}
+
+// RESPClient is a RemoteClient for servers speaking the Redis serialization
+// protocol (RESP), such as Redis, Valkey or KeyDB.
+type RESPClient struct {
+	opts RESPOptions
+	mu   sync.Mutex
+	idle []*respConn
+}
+
+// RESPError is an error reply sent by the server.
+type RESPError string
+
+func (e RESPError) Error() string {
+	return string(e)
+}
+
+type respConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+	wr   *bufio.Writer
+}
+
+// NewRESPClient creates a client for opts.Addr. Connections are opened on
+// first use.
+func NewRESPClient(opts RESPOptions) *RESPClient {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 4
+	}
+	return &RESPClient{opts: opts}
+}
+
+func (c *RESPClient) Get(key string) ([]byte, error) {
+	reply, err := c.Do("GET", key)
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrCacheMiss
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("resp: unexpected GET reply %T", reply)
+	}
+	return data, nil
+}
+
+func (c *RESPClient) Set(key string, value []byte, ttl time.Duration) error {
+	args := []interface{}{"SET", key, value}
+	if ttl > 0 {
+		ms := ttl.Milliseconds()
+		if ms == 0 {
+			ms = 1
+		}
+		args = append(args, "PX", strconv.FormatInt(ms, 10))
+	}
+	_, err := c.Do(args...)
+	return err
+}
+
+func (c *RESPClient) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := []interface{}{"DEL"}
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	_, err := c.Do(args...)
+	return err
+}
+
+// Keys walks the keyspace with SCAN so the server is never blocked by a
+// single large KEYS call.
+func (c *RESPClient) Keys(prefix string) ([]string, error) {
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := c.Do("SCAN", cursor, "MATCH", escapeRESPPattern(prefix)+"*", "COUNT", "100")
+		if err != nil {
+			return nil, err
+		}
+		parts, ok := reply.([]interface{})
+		if !ok || len(parts) != 2 {
+			return nil, fmt.Errorf("resp: unexpected SCAN reply %T", reply)
+		}
+		next, _ := parts[0].([]byte)
+		batch, _ := parts[1].([]interface{})
+		for _, key := range batch {
+			if b, ok := key.([]byte); ok {
+				keys = append(keys, string(b))
+			}
+		}
+		cursor = string(next)
+		if cursor == "0" || cursor == "" {
+			return keys, nil
+		}
+	}
+}
+
+// Do sends a command and returns its reply: a string for simple strings,
+// int64 for integers, []byte or nil for bulk strings and []interface{} for
+// arrays. Error replies are returned as RESPError. Arguments may be strings
+// or byte slices.
+func (c *RESPClient) Do(args ...interface{}) (interface{}, error) {
+	conn, err := c.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(c.opts.Timeout, args...)
+	var respErr RESPError
+	if err != nil && !errors.As(err, &respErr) {
+		// The connection state is unknown after an I/O failure.
+		conn.conn.Close()
+		return nil, err
+	}
+	c.release(conn)
+	return reply, err
+}
+
+// Close closes the idle connections.
+func (c *RESPClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.idle {
+		conn.conn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (c *RESPClient) conn() (*respConn, error) {
+	c.mu.Lock()
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	nc, err := net.DialTimeout("tcp", c.opts.Addr, c.opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn := &respConn{conn: nc, rd: bufio.NewReader(nc), wr: bufio.NewWriter(nc)}
+
+	if c.opts.Password != "" {
+		if _, err := conn.do(c.opts.Timeout, "AUTH", c.opts.Password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.opts.DB != 0 {
+		if _, err := conn.do(c.opts.Timeout, "SELECT", strconv.Itoa(c.opts.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (c *RESPClient) release(conn *respConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.idle) >= c.opts.MaxIdle {
+		conn.conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+func (rc *respConn) do(timeout time.Duration, args ...interface{}) (interface{}, error) {
+	rc.conn.SetDeadline(time.Now().Add(timeout))
+	if err := writeRESPCommand(rc.wr, args...); err != nil {
+		return nil, err
+	}
+	if err := rc.wr.Flush(); err != nil {
+		return nil, err
+	}
+	return readRESPReply(rc.rd)
+}
+
+// writeRESPCommand encodes args as an array of bulk strings.
+func writeRESPCommand(w *bufio.Writer, args ...interface{}) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		default:
+			return fmt.Errorf("resp: unsupported argument type %T", arg)
+		}
+		fmt.Fprintf(w, "$%d\r\n", len(b))
+		w.Write(b)
+		w.WriteString("\r\n")
+	}
+	return nil
+}
+
+// Limits on the lengths announced by a server, so that a corrupt reply
+// fails instead of exhausting memory. maxRESPBulkLen is the largest value a
+// Redis server accepts.
+const (
+	maxRESPBulkLen  = 512 << 20
+	maxRESPArrayLen = 1 << 20
+)
+
+func readRESPReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("resp: malformed reply %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, RESPError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if n > maxRESPBulkLen {
+			return nil, fmt.Errorf("resp: bulk string of %d bytes exceeds %d", n, maxRESPBulkLen)
+		}
+		// The buffer grows as data arrives rather than trusting n up front.
+		var data bytes.Buffer
+		if _, err := io.CopyN(&data, r, int64(n)+2); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return data.Bytes()[:n], nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		if n > maxRESPArrayLen {
+			return nil, fmt.Errorf("resp: array of %d items exceeds %d", n, maxRESPArrayLen)
+		}
+		items := []interface{}{}
+		for i := 0; i < n; i++ {
+			item, err := readRESPReply(r)
+			var respErr RESPError
+			if err != nil && !errors.As(err, &respErr) {
+				return nil, err
+			}
+			if err != nil {
+				item = respErr
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("resp: unknown reply type %q", kind)
+}
+
+// escapeRESPPattern escapes the glob metacharacters used by SCAN MATCH.
+func escapeRESPPattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,416 @@
+package router
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRESPServer is an in-process server answering the commands RESPClient
+// sends: AUTH, SELECT, GET, SET with PX, DEL and SCAN with MATCH and COUNT.
+type fakeRESPServer struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	values   map[string][]byte
+	expires  map[string]time.Time
+	commands map[string]int
+	gets     []string
+}
+
+func newFakeRESPServer(t *testing.T, password string) *fakeRESPServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on loopback: %v", err)
+	}
+	s := &fakeRESPServer{
+		ln:       ln,
+		password: password,
+		values:   map[string][]byte{},
+		expires:  map[string]time.Time{},
+		commands: map[string]int{},
+	}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeRESPServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *fakeRESPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeRESPServer) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		reply, err := readRESPReply(rd)
+		if err != nil {
+			return
+		}
+		parts, _ := reply.([]interface{})
+		args := make([]string, len(parts))
+		for i, p := range parts {
+			b, _ := p.([]byte)
+			args[i] = string(b)
+		}
+		if len(args) == 0 {
+			return
+		}
+
+		cmd := strings.ToUpper(args[0])
+		if !authed && cmd != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+		if cmd == "AUTH" {
+			if len(args) == 2 && args[1] == s.password {
+				authed = true
+				io.WriteString(conn, "+OK\r\n")
+			} else {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+			}
+			continue
+		}
+		io.WriteString(conn, s.exec(cmd, args[1:]))
+	}
+}
+
+func (s *fakeRESPServer) exec(cmd string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[cmd]++
+
+	for key, at := range s.expires {
+		if !time.Now().Before(at) {
+			delete(s.values, key)
+			delete(s.expires, key)
+		}
+	}
+
+	switch cmd {
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		s.gets = append(s.gets, args[0])
+		value, ok := s.values[args[0]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return respBulk(string(value))
+	case "SET":
+		s.values[args[0]] = []byte(args[1])
+		delete(s.expires, args[0])
+		if len(args) == 4 && strings.ToUpper(args[2]) == "PX" {
+			ms, err := strconv.Atoi(args[3])
+			if err != nil {
+				return "-ERR value is not an integer\r\n"
+			}
+			s.expires[args[0]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		n := 0
+		for _, key := range args {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				delete(s.expires, key)
+				n++
+			}
+		}
+		return ":" + strconv.Itoa(n) + "\r\n"
+	case "SCAN":
+		return s.scan(args)
+	}
+	return "-ERR unknown command '" + cmd + "'\r\n"
+}
+
+// scan pages through the sorted keyspace, using the position in it as the
+// cursor. Only patterns of the form prefix* are understood.
+func (s *fakeRESPServer) scan(args []string) string {
+	cursor, _ := strconv.Atoi(args[0])
+	prefix, count := "", 10
+	for i := 1; i+1 < len(args); i += 2 {
+		switch strings.ToUpper(args[i]) {
+		case "MATCH":
+			pattern := strings.TrimSuffix(args[i+1], "*")
+			var b strings.Builder
+			for j := 0; j < len(pattern); j++ {
+				if pattern[j] == '\\' && j+1 < len(pattern) {
+					j++
+				}
+				b.WriteByte(pattern[j])
+			}
+			prefix = b.String()
+		case "COUNT":
+			count, _ = strconv.Atoi(args[i+1])
+		}
+	}
+
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var page []string
+	next := cursor
+	for ; next < len(keys) && next < cursor+count; next++ {
+		if strings.HasPrefix(keys[next], prefix) {
+			page = append(page, keys[next])
+		}
+	}
+	if next >= len(keys) {
+		next = 0
+	}
+
+	var b strings.Builder
+	b.WriteString("*2\r\n" + respBulk(strconv.Itoa(next)))
+	fmt.Fprintf(&b, "*%d\r\n", len(page))
+	for _, key := range page {
+		b.WriteString(respBulk(key))
+	}
+	return b.String()
+}
+
+func respBulk(s string) string {
+	return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n"
+}
+
+func TestReadRESPReply(t *testing.T) {
+	tests := []struct {
+		reply   string
+		want    interface{}
+		wantErr bool
+	}{
+		{reply: "+OK\r\n", want: "OK"},
+		{reply: ":-3\r\n", want: int64(-3)},
+		{reply: "$3\r\nabc\r\n", want: []byte("abc")},
+		{reply: "$-1\r\n", want: nil},
+		{reply: "*0\r\n", want: []interface{}{}},
+		{reply: "*2\r\n:1\r\n-ERR x\r\n", want: []interface{}{int64(1), RESPError("ERR x")}},
+		{reply: "-ERR x\r\n", wantErr: true},
+		{reply: "$3\r\nab", wantErr: true},
+		{reply: "*2\r\n:1\r\n", wantErr: true},
+		{reply: "?\r\n", wantErr: true},
+		// Announced lengths are bounded rather than allocated.
+		{reply: "$9223372036854775807\r\n", wantErr: true},
+		{reply: "$" + strconv.Itoa(maxRESPBulkLen+1) + "\r\n", wantErr: true},
+		{reply: "$1000000\r\nshort\r\n", wantErr: true},
+		{reply: "*9223372036854775807\r\n", wantErr: true},
+		{reply: "*" + strconv.Itoa(maxRESPArrayLen+1) + "\r\n", wantErr: true},
+		{reply: "*99999999999999999999\r\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := readRESPReply(bufio.NewReader(strings.NewReader(tt.reply)))
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got %#v, want an error", tt.reply, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %#v, %v, want %#v", tt.reply, got, err, tt.want)
+		}
+	}
+}
+
+func TestRESPClient(t *testing.T) {
+	server := newFakeRESPServer(t, "secret")
+	client := NewRESPClient(RESPOptions{Addr: server.addr(), Password: "secret", DB: 2})
+	defer client.Close()
+
+	if _, err := client.Get("missing"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Get(missing) error = %v, want ErrCacheMiss", err)
+	}
+	value := []byte("binary\r\n\x00value")
+	if err := client.Set("a", value, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := client.Get("a"); err != nil || string(got) != string(value) {
+		t.Errorf("Get(a) = %q, %v", got, err)
+	}
+	if err := client.Delete("a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get("a"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Get after Delete error = %v, want ErrCacheMiss", err)
+	}
+
+	var respErr RESPError
+	if _, err := client.Do("FLUSHALL"); !errors.As(err, &respErr) {
+		t.Errorf("unknown command error = %v, want RESPError", err)
+	}
+	// The connection survives an error reply.
+	if err := client.Set("b", []byte("x"), 0); err != nil {
+		t.Errorf("Set after an error reply: %v", err)
+	}
+
+	bad := NewRESPClient(RESPOptions{Addr: server.addr(), Password: "wrong"})
+	defer bad.Close()
+	if _, err := bad.Get("b"); !errors.As(err, &respErr) {
+		t.Errorf("wrong password error = %v, want RESPError", err)
+	}
+}
+
+func TestRESPClientTTL(t *testing.T) {
+	server := newFakeRESPServer(t, "")
+	client := NewRESPClient(RESPOptions{Addr: server.addr()})
+	defer client.Close()
+
+	if err := client.Set("short", []byte("x"), 20*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Set("tiny", []byte("x"), time.Microsecond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Get("short"); err != nil {
+		t.Errorf("Get before expiry: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+	for _, key := range []string{"short", "tiny"} {
+		if _, err := client.Get(key); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("Get(%s) after expiry error = %v, want ErrCacheMiss", key, err)
+		}
+	}
+}
+
+func TestRESPClientKeys(t *testing.T) {
+	server := newFakeRESPServer(t, "")
+	client := NewRESPClient(RESPOptions{Addr: server.addr()})
+	defer client.Close()
+
+	want := map[string]bool{}
+	for i := 0; i < 250; i++ {
+		key := fmt.Sprintf("p*[1]:%03d", i)
+		want[key] = true
+		if err := client.Set(key, []byte("x"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 30; i++ {
+		if err := client.Set(fmt.Sprintf("other:%d", i), []byte("x"), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := client.Keys("p*[1]:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != len(want) {
+		t.Errorf("Keys returned %d keys, want %d", len(keys), len(want))
+	}
+	for _, key := range keys {
+		if !want[key] {
+			t.Errorf("Keys returned %q", key)
+		}
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if n := server.commands["SCAN"]; n < 3 {
+		t.Errorf("Keys made %d SCAN calls, want at least 3 pages", n)
+	}
+}
+
+func TestRemoteStore(t *testing.T) {
+	server := newFakeRESPServer(t, "")
+	client := NewRESPClient(RESPOptions{Addr: server.addr()})
+	defer client.Close()
+	store := NewRemoteStore(client, "api:")
+
+	entry := func(body string, tags ...string) *CacheEntry {
+		return &CacheEntry{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       []byte(body),
+			Expires:    time.Now().Add(time.Minute),
+			Tags:       tags,
+		}
+	}
+	entries := map[string]*CacheEntry{
+		"/a":     entry("a", "users"),
+		"/a?x=1": entry("ax"),
+		"/b":     entry("b", "orders"),
+	}
+	for key, e := range entries {
+		if err := store.Set(key, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Set("/expired", &CacheEntry{Expires: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Get("/a")
+	if err != nil || string(got.Body) != "a" || got.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("Get(/a) = %+v, %v", got, err)
+	}
+	if _, err := store.Get("/expired"); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("Get(/expired) error = %v, want ErrCacheMiss", err)
+	}
+
+	server.mu.Lock()
+	server.gets = nil
+	server.mu.Unlock()
+	err = store.DeleteFunc(func(key string, e *CacheEntry) bool {
+		if e.Body != nil {
+			t.Errorf("DeleteFunc passed the body of %s", key)
+		}
+		for _, tag := range e.Tags {
+			if tag == "users" {
+				return true
+			}
+		}
+		return key == "/b"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	for _, key := range server.gets {
+		if strings.HasPrefix(key, "api:"+remoteEntryPrefix) {
+			t.Errorf("DeleteFunc read the entry %s", key)
+		}
+	}
+	server.mu.Unlock()
+
+	for key := range entries {
+		_, err := store.Get(key)
+		if deleted := errors.Is(err, ErrCacheMiss); deleted != (key != "/a?x=1") {
+			t.Errorf("after DeleteFunc, Get(%s) error = %v", key, err)
+		}
+	}
+
+	if err := store.Delete("/a?x=1"); err != nil {
+		t.Fatal(err)
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if len(server.values) != 0 {
+		t.Errorf("keys left on the server: %d", len(server.values))
+	}
+}
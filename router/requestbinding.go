@@ -1,100 +1,144 @@
 package router
 
 import (
-	"encoding/json"
 	"encoding/xml"
 	"errors"
-	"fmt"
+	"io"
 	"net/http"
-	"reflect"
-	"strconv"
 	"strings"
 )
 
+// hasContentType reports whether the Content-Type of r names one of
+// mediaTypes. Media types are case-insensitive.
+func hasContentType(r *http.Request, mediaTypes ...string) bool {
+	contentType := strings.ToLower(r.Header.Get("Content-Type"))
+	for _, mediaType := range mediaTypes {
+		if strings.Contains(contentType, mediaType) {
+			return true
+		}
+	}
+	return false
+}
 
 func BindJSON(r *http.Request, v interface{}) error {
-	contentType := r.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "application/json") {
+	if !hasContentType(r, "application/json") {
 		return errors.New("content type is not application/json")
 	}
 
+	return decodeJSON(r, v)
+}
+
+func decodeJSON(r *http.Request, v interface{}) error {
+	return decodeJSONWith(r, v, JSONOptions{})
+}
+
+func BindXML(r *http.Request, v interface{}) error {
+	if !hasContentType(r, "application/xml", "text/xml") {
+		return errors.New("content type is not xml")
+	}
+
+	return decodeXML(r, v)
+}
+
+func decodeXML(r *http.Request, v interface{}) error {
 	if r.Body == nil {
 		return errors.New("request body is empty")
 	}
 
-	decoder := json.NewDecoder(r.Body)
+	decoder := xml.NewDecoder(r.Body)
 	if err := decoder.Decode(v); err != nil {
-		return fmt.Errorf("failed to decode JSON: %v", err)
+		return err
 	}
 
 	return nil
 }
+// maxBinaryBodySize caps the MessagePack and CBOR bodies read by BindMsgPack
+// and BindCBOR, which must be held in memory whole before decoding.
+const maxBinaryBodySize = 10 << 20
+
+// readBody reads the body of r, yielding a *RequestTooLargeError when it
+// exceeds limit bytes.
+func readBody(r *http.Request, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, limit))
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return nil, &RequestTooLargeError{Limit: maxBytes.Limit}
+		}
+		return nil, err
+	}
+	return data, nil
+}
 
-func BindXML(r *http.Request, v interface{}) error {
-	contentType := r.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "application/xml") && !strings.Contains(contentType, "text/xml") {
-		return errors.New("content type is not xml")
+// BindMsgPack decodes a MessagePack body into v, matching map keys to json
+// struct tags. Bodies over 10 MB yield a *RequestTooLargeError.
+func BindMsgPack(r *http.Request, v interface{}) error {
+	if !hasContentType(r, "application/msgpack", "application/x-msgpack") {
+		return errors.New("content type is not msgpack")
 	}
 
 	if r.Body == nil {
 		return errors.New("request body is empty")
 	}
 
-	decoder := xml.NewDecoder(r.Body)
-	if err := decoder.Decode(v); err != nil {
+	data, err := readBody(r, maxBinaryBodySize)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	return UnmarshalMsgPack(data, v)
 }
-func BindForm(r *http.Request, v interface{}) error {
-	contentType := r.Header.Get("Content-Type")
-	if !strings.Contains(contentType, "application/x-www-form-urlencoded") {
-		return errors.New("content type is not application/x-www-form-urlencoded")
+
+// BindCBOR decodes a CBOR body into v, matching map keys to json struct tags.
+// Bodies over 10 MB yield a *RequestTooLargeError.
+func BindCBOR(r *http.Request, v interface{}) error {
+	if !hasContentType(r, "application/cbor") {
+		return errors.New("content type is not application/cbor")
 	}
 
-	if err := r.ParseForm(); err != nil {
-		return err
+	if r.Body == nil {
+		return errors.New("request body is empty")
 	}
 
-	val := reflect.ValueOf(v).Elem()
-	typ := val.Type()
+	data, err := readBody(r, maxBinaryBodySize)
+	if err != nil {
+		return err
+	}
 
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
+	return UnmarshalCBOR(data, v)
+}
 
-		formValue := r.FormValue(fieldType.Name)
-		if formValue == "" {
-			continue
-		}
+// BindForm decodes a URL-encoded form into v, a pointer to a struct. Fields
+// are named by their form tag, or by their Go name when untagged:
+//
+//	type Order struct {
+//		Email string    `form:"email"`
+//		Due   time.Time `form:"due"`      // RFC 3339
+//		Tags  []string  `form:"tags"`     // tags=a&tags=b or tags[]=a
+//		Items []Item    `form:"items"`    // items[0].name or items[0][name]
+//		Ship  *Address  `form:"shipping"` // shipping.city or shipping[city]
+//	}
+//
+// Values are converted as BindRequest converts them. Empty values leave the
+// field unchanged. Every conversion failure is reported in the returned
+// BindErrors.
+func BindForm(r *http.Request, v interface{}) error {
+	if !hasContentType(r, "application/x-www-form-urlencoded") {
+		return errors.New("content type is not application/x-www-form-urlencoded")
+	}
 
-		switch field.Kind() {
-		case reflect.String:
-			field.SetString(formValue)
-		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			intValue, err := strconv.ParseInt(formValue, 10, 64)
-			if err != nil {
-				return err
-			}
-			field.SetInt(intValue)
-		case reflect.Float32, reflect.Float64:
-			floatValue, err := strconv.ParseFloat(formValue, 64)
-			if err != nil {
-				return err
-			}
-			field.SetFloat(floatValue)
-		case reflect.Bool:
-			boolValue, err := strconv.ParseBool(formValue)
-			if err != nil {
-				return err
-			}
-			field.SetBool(boolValue)
-		// Add other types as needed
-		default:
-			return errors.New("unsupported field type")
-		}
+	rv, err := bindTarget(v)
+	if err != nil {
+		return err
+	}
+	if err := r.ParseForm(); err != nil {
+		return err
 	}
 
+	var errs BindErrors
+	bindFormStruct(newFormTree(r.Form), rv, "", "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
 	return nil
-}
\ No newline at end of file
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -13,9 +14,10 @@ import (
 )
 
 type Router struct {
-	routes      []Route
+	routes      []*Route
 	middleware  MiddlewareChain // Changed to MiddlewareChain
 	routeGroups map[string]*RouteGroup
+	namedRoutes map[string]*Route
 }
 
 type Route struct {
@@ -25,6 +27,9 @@ type Route struct {
 	pattern    *regexp.Regexp
 	params     map[int]string
 	middleware MiddlewareChain // Changed to MiddlewareChain
+	name       string
+	router     *Router
+	typed      *TypedHandler
 }
 
 type Middleware func(http.Handler) http.Handler
@@ -40,6 +45,7 @@ type RouteGroup struct { // Added for versioning
 var (
 	customTypes        = map[string]string{}
 	validFilenameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]+\.[a-zA-Z0-9]+$`)
+	pathParamRegex     = regexp.MustCompile(`{(\w+)(?::(\w+))?}`)
 )
 
 var typePatterns = map[string]*regexp.Regexp{
@@ -63,27 +69,91 @@ func isValidFilename(filename string) bool {
 func NewRouter() *Router {
 	return &Router{
 		routeGroups: make(map[string]*RouteGroup), // Initialize the routeGroups map
+		namedRoutes: make(map[string]*Route),
 	}
 }
-func (g *RouteGroup) Handle(method, path string, handler http.HandlerFunc, middleware ...Middleware) {
+func (g *RouteGroup) Handle(method, path string, handler http.HandlerFunc, middleware ...Middleware) *Route {
 	fullPath := g.prefix + path // Prepend the group prefix to the path
 	middleware = append(middleware, g.middleware...) // Add the group's middleware to the route's middleware
-	g.router.Handle(method, fullPath, handler, middleware...)
+	return g.router.Handle(method, fullPath, handler, middleware...)
 }
 
 
-// Handle adds a new route to the router
-func (r *Router) Handle(method, path string, handler http.HandlerFunc, middleware ...Middleware) {
+// Handle adds a new route to the router and returns it so it can be named
+func (r *Router) Handle(method, path string, handler http.HandlerFunc, middleware ...Middleware) *Route {
 	pattern, params := parsePath(path)
-	route := Route{
+	route := &Route{
 		path:       path,
 		method:     method,
 		handler:    handler,
 		pattern:    pattern,
 		params:     params,
 		middleware: middleware, // This now directly assigns the slice of middleware
+		router:     r,
 	}
 	r.routes = append(r.routes, route)
+	return route
+}
+
+// Name registers the route under name so that URLs can be built for it with
+// Router.URL. Names must be unique within a router.
+func (route *Route) Name(name string) *Route {
+	r := route.router
+	if r.namedRoutes == nil {
+		r.namedRoutes = make(map[string]*Route)
+	}
+	if _, exists := r.namedRoutes[name]; exists {
+		panic(fmt.Sprintf("router: duplicate route name %q", name))
+	}
+	route.name = name
+	r.namedRoutes[name] = route
+	return route
+}
+
+// URL builds the path of the route registered under name, filling its
+// parameters from key/value pairs:
+//
+//	r.GET("/users/{id:int}", showUser).Name("user")
+//	r.URL("user", "id", "42") // "/users/42"
+//
+// Values are path-escaped. An error is returned for an unknown name, a
+// missing parameter or a value that does not match the parameter's type.
+func (r *Router) URL(name string, params ...string) (string, error) {
+	route, exists := r.namedRoutes[name]
+	if !exists {
+		return "", fmt.Errorf("router: no route named %q", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("router: odd number of parameters for route %q", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		values[params[i]] = params[i+1]
+	}
+
+	var missing []string
+	var raw, escaped strings.Builder
+	last := 0
+	for _, loc := range pathParamRegex.FindAllStringSubmatchIndex(route.path, -1) {
+		param := route.path[loc[2]:loc[3]]
+		value, ok := values[param]
+		if !ok {
+			missing = append(missing, param)
+		}
+		raw.WriteString(route.path[last:loc[0]] + value)
+		escaped.WriteString(route.path[last:loc[0]] + url.PathEscape(value))
+		last = loc[1]
+	}
+	raw.WriteString(route.path[last:])
+	escaped.WriteString(route.path[last:])
+
+	if len(missing) > 0 {
+		return "", fmt.Errorf("router: missing parameters %s for route %q", strings.Join(missing, ", "), name)
+	}
+	if !route.pattern.MatchString(raw.String()) {
+		return "", fmt.Errorf("router: parameters do not match route %q", name)
+	}
+	return escaped.String(), nil
 }
 func (r *Router) Group(version string) *RouteGroup {
 	if group, exists := r.routeGroups[version]; exists {
@@ -116,8 +186,6 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 					params[name] = matches[i+1]
 				}
 
-				type key int
-				const paramsKey key = 0
 				ctx := context.WithValue(req.Context(), paramsKey, params)
 				req = req.WithContext(ctx)
 
@@ -163,10 +231,14 @@ func AddCustomType(name, pattern string) error {
 
 // Param function to extract parameters from the request context
 func Param(r *http.Request, name string) string {
-	type key int
-	const paramsKey key = 0
-	params := r.Context().Value(paramsKey).(map[string]string)
-	return params[name]
+	return pathParams(r)[name]
+}
+
+// pathParams returns the parameters of the matched route, or nil outside a
+// routed request.
+func pathParams(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(paramsKey).(map[string]string)
+	return params
 }
 
 // RegisterParamType allows the registration of custom parameter types with specific regex patterns
@@ -175,9 +247,7 @@ func RegisterParamType(name, pattern string) {
 }
 
 func parsePath(path string) (*regexp.Regexp, map[int]string) {
-	paramRe := regexp.MustCompile(`{(\w+)(?::(\w+))?}`) // Updated regex to capture optional type
-
-	matches := paramRe.FindAllStringSubmatch(path, -1)
+	matches := pathParamRegex.FindAllStringSubmatch(path, -1) // Captures the name and optional type
 	params := make(map[int]string)
 
 	for i, match := range matches {
@@ -0,0 +1,282 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// HTTPError is an error with an HTTP status, for typed handlers to return
+// when a request cannot be served, such as 404 for a missing resource.
+type HTTPError struct {
+	Status  int
+	Message string
+	err     error
+}
+
+// NewHTTPError returns an *HTTPError; an empty message defaults to the
+// status text.
+func NewHTTPError(status int, message string) *HTTPError {
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &HTTPError{Status: status, Message: message}
+}
+
+func (e *HTTPError) Error() string {
+	return e.Message
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode returns the error's status.
+func (e *HTTPError) StatusCode() int {
+	return e.Status
+}
+
+// HandlerInfo describes a typed handler for documentation generators.
+type HandlerInfo struct {
+	// Request and Response are the handler's request and response types.
+	Request  reflect.Type
+	Response reflect.Type
+	// Status is the status of successful responses.
+	Status int
+	// Params lists the request fields bound from the path, query string,
+	// headers and cookies. Other fields come from the body.
+	Params []ParamInfo
+}
+
+// ParamInfo describes a request field bound by BindRequest.
+type ParamInfo struct {
+	// Source is "path", "query", "header" or "cookie".
+	Source string
+	Name   string
+	Type   reflect.Type
+	// Required is set when the field's validate tag includes required.
+	Required bool
+}
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Method string
+	Path   string
+	Name   string
+	// Handler describes the route's typed handler, or is nil when the route
+	// has an ordinary handler.
+	Handler *HandlerInfo
+}
+
+// TypedOption customizes a handler created by Typed.
+type TypedOption func(*typedOptions)
+
+type typedOptions struct {
+	status       int
+	errorHandler func(http.ResponseWriter, *http.Request, error)
+}
+
+// WithStatus sets the status of successful responses, 200 by default. With
+// 204 No Content nothing is written after the header.
+func WithStatus(status int) TypedOption {
+	return func(o *typedOptions) {
+		o.status = status
+	}
+}
+
+// WithErrorHandler replaces the default rendering of errors from binding,
+// validation and the handler function.
+func WithErrorHandler(handle func(w http.ResponseWriter, r *http.Request, err error)) TypedOption {
+	return func(o *typedOptions) {
+		o.errorHandler = handle
+	}
+}
+
+// TypedHandler is an http.Handler built by Typed.
+type TypedHandler struct {
+	info  HandlerInfo
+	serve http.HandlerFunc
+}
+
+// ServeHTTP handles the request.
+func (h *TypedHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serve(w, r)
+}
+
+// Info describes the handler.
+func (h *TypedHandler) Info() HandlerInfo {
+	return h.info
+}
+
+// Typed adapts fn to an http.Handler. Each request is bound into a Req with
+// BindRequest, so its fields may come from the body, the path, the query
+// string, headers and cookies, and validated with ValidateStruct. fn is then
+// called with the request's context, and its result is sent with Respond:
+//
+//	func createUser(ctx context.Context, req CreateUser) (User, error) { ... }
+//
+//	r.HandleTyped("POST", "/users", router.Typed(createUser, router.WithStatus(http.StatusCreated)))
+//
+// Req must be a struct or a pointer to one. Errors are sent as problem
+// details: a *ValidationError as it is, errors with a StatusCode method, such
+// as *HTTPError and the binding errors, with their status and message, and
+// any other error from fn as a 500 without details, logging the error. Other
+// binding errors, such as a malformed XML body, are 400s.
+func Typed[Req, Resp any](fn func(ctx context.Context, req Req) (Resp, error), opts ...TypedOption) *TypedHandler {
+	o := typedOptions{status: http.StatusOK, errorHandler: respondTypedError}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	structType := reqType
+	if structType.Kind() == reflect.Pointer {
+		structType = structType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		panic("router: Typed request type must be a struct, got " + reqType.String())
+	}
+
+	h := &TypedHandler{info: HandlerInfo{
+		Request:  reqType,
+		Response: reflect.TypeOf((*Resp)(nil)).Elem(),
+		Status:   o.status,
+		Params:   paramInfo(structType, nil),
+	}}
+	h.serve = func(w http.ResponseWriter, r *http.Request) {
+		target := reflect.New(structType)
+		if err := BindRequest(r, target.Interface(), WithValidation()); err != nil {
+			// Decoders such as encoding/xml return plain errors for
+			// malformed bodies; they are the client's fault all the same.
+			var coded interface{ StatusCode() int }
+			if !errors.As(err, &coded) {
+				err = &HTTPError{Status: http.StatusBadRequest, Message: err.Error(), err: err}
+			}
+			o.errorHandler(w, r, err)
+			return
+		}
+		var req Req
+		if reqType.Kind() == reflect.Pointer {
+			req = target.Interface().(Req)
+		} else {
+			req = target.Elem().Interface().(Req)
+		}
+
+		resp, err := fn(r.Context(), req)
+		if err != nil {
+			o.errorHandler(w, r, err)
+			return
+		}
+		if o.status == http.StatusNoContent {
+			w.WriteHeader(o.status)
+			return
+		}
+		Respond(w, r, o.status, resp)
+	}
+	return h
+}
+
+// respondTypedError is the default error handler of typed handlers.
+func respondTypedError(w http.ResponseWriter, r *http.Request, err error) {
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		RespondProblem(w, r, invalid.StatusCode(), invalid)
+		return
+	}
+	var coded interface{ StatusCode() int }
+	if errors.As(err, &coded) {
+		status := coded.StatusCode()
+		RespondProblem(w, r, status, NewProblem(r, status, err.Error()))
+		return
+	}
+	log.Printf("typed handler %s %s: %v", r.Method, r.URL.Path, err)
+	RespondProblem(w, r, http.StatusInternalServerError, NewProblem(r, http.StatusInternalServerError, ""))
+}
+
+// paramInfo lists the fields of struct type t that BindRequest sets from
+// outside the body, searching nested structs as bindTaggedFields does.
+func paramInfo(t reflect.Type, visited map[reflect.Type]bool) []ParamInfo {
+	if visited == nil {
+		visited = map[reflect.Type]bool{}
+	}
+	if visited[t] {
+		return nil
+	}
+	visited[t] = true
+	defer delete(visited, t)
+
+	var params []ParamInfo
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tagged := false
+		for _, source := range bindSources {
+			name, ok := sf.Tag.Lookup(source)
+			if !ok || name == "-" {
+				continue
+			}
+			tagged = true
+			if sf.IsExported() {
+				params = append(params, ParamInfo{
+					Source:   source,
+					Name:     name,
+					Type:     sf.Type,
+					Required: hasValidateRule(sf.Tag.Get("validate"), "required"),
+				})
+			}
+		}
+		if tagged {
+			continue
+		}
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct && ft != timeType && (sf.IsExported() || sf.Anonymous) {
+			params = append(params, paramInfo(ft, visited)...)
+		}
+	}
+	return params
+}
+
+// hasValidateRule reports whether the validate tag lists rule.
+func hasValidateRule(tag, rule string) bool {
+	for _, r := range strings.Split(tag, ",") {
+		name, _, _ := strings.Cut(r, "=")
+		if name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleTyped adds a route served by a typed handler; its description is
+// listed by Routes.
+func (r *Router) HandleTyped(method, path string, h *TypedHandler, middleware ...Middleware) *Route {
+	route := r.Handle(method, path, h.ServeHTTP, middleware...)
+	route.typed = h
+	return route
+}
+
+// HandleTyped adds a route served by a typed handler to the group.
+func (g *RouteGroup) HandleTyped(method, path string, h *TypedHandler, middleware ...Middleware) *Route {
+	route := g.Handle(method, path, h.ServeHTTP, middleware...)
+	route.typed = h
+	return route
+}
+
+// Routes describes the registered routes in the order they were added.
+func (r *Router) Routes() []RouteInfo {
+	routes := make([]RouteInfo, len(r.routes))
+	for i, route := range r.routes {
+		routes[i] = RouteInfo{Method: route.method, Path: route.path, Name: route.name}
+		if route.typed != nil {
+			info := route.typed.Info()
+			routes[i].Handler = &info
+		}
+	}
+	return routes
+}
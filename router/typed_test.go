@@ -0,0 +1,160 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type typedRequest struct {
+	ID     int    `path:"id"`
+	Limit  int    `query:"limit"`
+	Tenant string `header:"X-Tenant" validate:"required"`
+	Name   string `json:"name" xml:"name" validate:"required"`
+}
+
+type typedResponse struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestTyped(t *testing.T) {
+	h := Typed(func(ctx context.Context, req typedRequest) (typedResponse, error) {
+		switch req.Name {
+		case "missing":
+			return typedResponse{}, NewHTTPError(http.StatusNotFound, "")
+		case "broken":
+			return typedResponse{}, errors.New("database is down")
+		}
+		return typedResponse{ID: req.ID, Name: req.Name}, nil
+	}, WithStatus(http.StatusCreated))
+
+	r := NewRouter()
+	r.HandleTyped("POST", "/users/{id:int}", h)
+
+	tests := []struct {
+		contentType, body string
+		tenant            string
+		status            int
+	}{
+		{"application/json", `{"name":"ann"}`, "acme", http.StatusCreated},
+		{"application/json", `{"name":"ann"}`, "", http.StatusUnprocessableEntity},
+		{"application/json", `{"name":`, "acme", http.StatusBadRequest},
+		{"application/json", `{"name":"missing"}`, "acme", http.StatusNotFound},
+		{"application/json", `{"name":"broken"}`, "acme", http.StatusInternalServerError},
+		{"text/plain", `ann`, "acme", http.StatusUnsupportedMediaType},
+		// Malformed bodies whose decoders return plain errors are still the
+		// client's fault.
+		{"application/xml", `<bad`, "acme", http.StatusBadRequest},
+		{"application/cbor", "\xff\xff", "acme", http.StatusBadRequest},
+		{"application/msgpack", "\xc1", "acme", http.StatusBadRequest},
+		{"application/x-www-form-urlencoded", "name=%zz", "acme", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/users/7", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		if tt.tenant != "" {
+			req.Header.Set("X-Tenant", tt.tenant)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s %q: status = %d, want %d: %s", tt.contentType, tt.body, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.status == http.StatusCreated {
+			var got typedResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got != (typedResponse{7, "ann"}) {
+				t.Errorf("%s %q: body = %s", tt.contentType, tt.body, w.Body)
+			}
+			continue
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+			t.Errorf("%s %q: Content-Type = %q, want problem details", tt.contentType, tt.body, ct)
+		}
+		if tt.status == http.StatusInternalServerError && strings.Contains(w.Body.String(), "database") {
+			t.Errorf("%s %q: server error details leaked: %s", tt.contentType, tt.body, w.Body)
+		}
+	}
+}
+
+func TestTypedBindErrorUnwraps(t *testing.T) {
+	var got error
+	h := Typed(func(ctx context.Context, req *typedRequest) (typedResponse, error) {
+		return typedResponse{}, nil
+	}, WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+		got = err
+	}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("<bad"))
+	req.Header.Set("Content-Type", "application/xml")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var herr *HTTPError
+	if !errors.As(got, &herr) || herr.StatusCode() != http.StatusBadRequest {
+		t.Fatalf("error = %v, want a 400 *HTTPError", got)
+	}
+	if errors.Unwrap(herr) == nil {
+		t.Error("the decoder's error was not kept")
+	}
+}
+
+func TestTypedNoContent(t *testing.T) {
+	h := Typed(func(ctx context.Context, req struct{}) (struct{}, error) {
+		return struct{}{}, nil
+	}, WithStatus(http.StatusNoContent))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/", nil))
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("got %d with body %q, want an empty 204", w.Code, w.Body)
+	}
+}
+
+func TestTypedRequestType(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Typed accepted a request type that is not a struct")
+		}
+	}()
+	Typed(func(ctx context.Context, req string) (string, error) { return req, nil })
+}
+
+func TestRoutes(t *testing.T) {
+	r := NewRouter()
+	r.Handle("GET", "/health", func(w http.ResponseWriter, r *http.Request) {}).Name("health")
+	r.HandleTyped("POST", "/users/{id:int}", Typed(func(ctx context.Context, req typedRequest) (typedResponse, error) {
+		return typedResponse{}, nil
+	}, WithStatus(http.StatusCreated)))
+
+	routes := r.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("got %d routes, want 2", len(routes))
+	}
+	if routes[0].Name != "health" || routes[0].Handler != nil {
+		t.Errorf("routes[0] = %+v", routes[0])
+	}
+
+	info := routes[1].Handler
+	if info == nil {
+		t.Fatal("typed route has no handler description")
+	}
+	if info.Request != reflect.TypeOf(typedRequest{}) || info.Response != reflect.TypeOf(typedResponse{}) || info.Status != http.StatusCreated {
+		t.Errorf("handler = %+v", info)
+	}
+	want := []ParamInfo{
+		{Source: "path", Name: "id", Type: reflect.TypeOf(0)},
+		{Source: "query", Name: "limit", Type: reflect.TypeOf(0)},
+		{Source: "header", Name: "X-Tenant", Type: reflect.TypeOf(""), Required: true},
+	}
+	if !reflect.DeepEqual(info.Params, want) {
+		t.Errorf("params = %+v, want %+v", info.Params, want)
+	}
+}
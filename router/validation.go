@@ -0,0 +1,173 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Name fields in errors and messages as clients know them.
+	validate.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+}
+
+// FieldError describes one failed validation rule.
+type FieldError struct {
+	// Field is the path of the field using json names, such as
+	// items[0].qty, and Pointer the same location as a JSON pointer.
+	Field   string `json:"field" xml:"field"`
+	Pointer string `json:"pointer" xml:"pointer"`
+	// Rule is the validate tag that failed and Param its parameter.
+	Rule    string `json:"rule" xml:"rule"`
+	Param   string `json:"param,omitempty" xml:"param,omitempty"`
+	Message string `json:"message" xml:"message"`
+}
+
+// ValidationError is a problem details object listing the fields of a
+// request that failed validation. Send it with Respond or RespondProblem
+// using its StatusCode.
+type ValidationError struct {
+	Problem
+	Errors []FieldError `json:"errors" xml:"errors>error"`
+	err    error
+}
+
+// NewValidationError converts an error returned by ValidateStruct into a
+// *ValidationError with messages in the language of r. It returns nil for
+// errors that did not come from validation.
+func NewValidationError(r *http.Request, err error) *ValidationError {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return nil
+	}
+
+	trans := Translator(r)
+	translatorsMu.RLock()
+	fallback := translators.GetFallback()
+	translatorsMu.RUnlock()
+
+	v := &ValidationError{
+		Problem: *NewProblem(r, http.StatusUnprocessableEntity, "The request failed validation."),
+		Errors:  make([]FieldError, len(errs)),
+		err:     err,
+	}
+	for i, fe := range errs {
+		field := fe.Namespace()
+		// Drop the name of the validated struct.
+		if _, rest, ok := strings.Cut(field, "."); ok {
+			field = rest
+		}
+		v.Errors[i] = FieldError{
+			Field:   field,
+			Pointer: jsonPointer(formKeySegments(field)),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: validationMessage(fe, trans, fallback),
+		}
+	}
+	return v
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		messages[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode returns 422 Unprocessable Content.
+func (e *ValidationError) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// validationMessage translates fe with trans, then with fallback, then
+// describes it in plain English.
+func validationMessage(fe validator.FieldError, trans, fallback ut.Translator) string {
+	for _, t := range []ut.Translator{trans, fallback} {
+		if message := fe.Translate(t); message != fe.Error() {
+			return message
+		}
+	}
+	if fe.Param() != "" {
+		return fmt.Sprintf("%s failed the %s=%s rule", fe.Field(), fe.Tag(), fe.Param())
+	}
+	return fmt.Sprintf("%s failed the %s rule", fe.Field(), fe.Tag())
+}
+
+// jsonPointer formats a path as an RFC 6901 JSON pointer.
+func jsonPointer(segments []string) string {
+	var b strings.Builder
+	for _, segment := range segments {
+		b.WriteString("/")
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(segment))
+	}
+	return b.String()
+}
+
+// RegisterValidation adds a rule to the validator used by ValidateStruct,
+// applied to fields with tag in their validate tag. message, if not empty,
+// is the rule's English message; {0} stands for the field and {1} for the
+// rule's parameter. Register rules during initialization, before any
+// validation runs.
+//
+//	router.RegisterValidation("sku", func(fl validator.FieldLevel) bool {
+//		return skuPattern.MatchString(fl.Field().String())
+//	}, "{0} must be a valid SKU")
+func RegisterValidation(tag string, fn validator.Func, message string) error {
+	if err := validate.RegisterValidation(tag, fn); err != nil {
+		return err
+	}
+	if message == "" {
+		return nil
+	}
+	return RegisterValidationMessage("en", tag, message)
+}
+
+// RegisterValidationMessage sets the message for the validation rule tag in
+// a registered language, with the placeholders of RegisterValidation.
+func RegisterValidationMessage(language, tag, message string) error {
+	translatorsMu.RLock()
+	trans, found := translators.GetTranslator(tagLocale(language))
+	translatorsMu.RUnlock()
+	if !found {
+		return errors.New("i18n: no translator registered for " + language)
+	}
+
+	return validate.RegisterTranslation(tag, trans,
+		func(t ut.Translator) error {
+			return t.Add(tag, message, true)
+		},
+		func(t ut.Translator, fe validator.FieldError) string {
+			text, ok := translate(t, fe.Tag(), []string{fe.Field(), fe.Param()})
+			if !ok {
+				return fe.Error()
+			}
+			return text
+		})
+}
+
+// validationResult runs ValidateStruct on v for a binder, converting
+// validation failures into a *ValidationError.
+func validationResult(r *http.Request, v interface{}) error {
+	err := ValidateStruct(v)
+	if verr := NewValidationError(r, err); verr != nil {
+		return verr
+	}
+	return err
+}
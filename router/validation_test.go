@@ -0,0 +1,154 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var registerEvenRule sync.Once
+
+// withEvenRule registers an "even" rule with English and French messages.
+func withEvenRule(t *testing.T) {
+	t.Helper()
+	withFrench(t)
+	registerEvenRule.Do(func() {
+		even := func(fl validator.FieldLevel) bool { return fl.Field().Int()%2 == 0 }
+		if err := RegisterValidation("even", even, "{0} must be even"); err != nil {
+			t.Fatal(err)
+		}
+		if err := RegisterValidationMessage("fr", "even", "{0} doit être pair"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+type validationLine struct {
+	SKU string `json:"sku" validate:"required"`
+	Qty int    `json:"qty" validate:"min=1,even"`
+}
+
+type validationOrder struct {
+	Email string                    `json:"email" validate:"required,email"`
+	Code  string                    `json:"code" validate:"len=3"`
+	Odd   int                       `validate:"even"`
+	Items []validationLine          `json:"items" validate:"dive"`
+	Ship  map[string]validationLine `json:"ship/to" validate:"dive"`
+	Note  string                    `json:"-" validate:"startswith=x"`
+}
+
+func TestNewValidationError(t *testing.T) {
+	withEvenRule(t)
+	order := validationOrder{
+		Email: "nope",
+		Code:  "abc",
+		Odd:   2,
+		Items: []validationLine{{SKU: "a", Qty: 2}, {Qty: 3}},
+		Ship:  map[string]validationLine{"home": {SKU: "b", Qty: 0}},
+		Note:  "y",
+	}
+	r := languageRequest("en")
+	v := NewValidationError(r, ValidateStruct(order))
+	if v == nil {
+		t.Fatal("NewValidationError returned nil for a validation error")
+	}
+
+	want := []FieldError{
+		{Field: "email", Pointer: "/email", Rule: "email", Message: "email must be a valid email address"},
+		{Field: "items[1].sku", Pointer: "/items/1/sku", Rule: "required", Message: "sku is a required field"},
+		{Field: "items[1].qty", Pointer: "/items/1/qty", Rule: "even", Message: "qty must be even"},
+		{Field: "ship/to[home].qty", Pointer: "/ship~1to/home/qty", Rule: "min", Param: "1", Message: "qty must be 1 or greater"},
+		{Field: "Note", Pointer: "/Note", Rule: "startswith", Param: "x", Message: "Note failed the startswith=x rule"},
+	}
+	if !reflect.DeepEqual(v.Errors, want) {
+		got, _ := json.MarshalIndent(v.Errors, "", "  ")
+		t.Errorf("errors:\n%s", got)
+	}
+	if v.Status != http.StatusUnprocessableEntity || v.StatusCode() != http.StatusUnprocessableEntity || v.Title != "Unprocessable Entity" {
+		t.Errorf("problem = %+v", v.Problem)
+	}
+	if !strings.HasPrefix(v.Error(), "validation failed: email: email must be a valid email address; items[1].sku: ") {
+		t.Errorf("Error() = %q", v.Error())
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(v, &errs) || len(errs) != len(want) {
+		t.Error("the validator's errors are not reachable through Unwrap")
+	}
+
+	if NewValidationError(r, errors.New("other")) != nil || NewValidationError(r, nil) != nil {
+		t.Error("NewValidationError converted an error that is not from validation")
+	}
+}
+
+func TestValidationErrorLanguage(t *testing.T) {
+	withEvenRule(t)
+	type form struct {
+		Qty int `json:"qty" validate:"even"`
+		Max int `json:"max" validate:"max=1"`
+		Odd int `json:"odd" validate:"ne=3"`
+	}
+	f := form{Qty: 1, Max: 2, Odd: 3}
+	tests := []struct {
+		language string
+		want     []string
+	}{
+		{"en", []string{"qty must be even", "max must be 1 or less", "odd should not be equal to 3"}},
+		{"fr", []string{"qty doit être pair", "max doit être égal à 1 ou moins", "odd ne doit pas être égal à 3"}},
+		// Languages without a translator fall back to English.
+		{"de", []string{"qty must be even", "max must be 1 or less", "odd should not be equal to 3"}},
+	}
+
+	for _, tt := range tests {
+		v := NewValidationError(languageRequest(tt.language), ValidateStruct(f))
+		var got []string
+		for _, fe := range v.Errors {
+			got = append(got, fe.Message)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: messages %q, want %q", tt.language, got, tt.want)
+		}
+	}
+}
+
+func TestValidationMessageFallback(t *testing.T) {
+	if err := validate.RegisterValidation("router_test_untranslated", func(validator.FieldLevel) bool { return false }); err != nil {
+		t.Fatal(err)
+	}
+	type form struct {
+		A int `json:"a" validate:"router_test_untranslated"`
+		B int `json:"b" validate:"router_test_untranslated=5"`
+	}
+	v := NewValidationError(languageRequest("en"), ValidateStruct(form{}))
+	if v.Errors[0].Message != "a failed the router_test_untranslated rule" || v.Errors[1].Message != "b failed the router_test_untranslated=5 rule" {
+		t.Errorf("messages %q, %q", v.Errors[0].Message, v.Errors[1].Message)
+	}
+
+	if err := RegisterValidationMessage("xx", "even", "x"); err == nil {
+		t.Error("RegisterValidationMessage for an unregistered language succeeded")
+	}
+}
+
+func TestRespondProblemValidation(t *testing.T) {
+	type form struct {
+		Name string `json:"name" validate:"required"`
+	}
+	r := httptest.NewRequest("POST", "/", nil)
+	r.Header.Set("Accept", "application/xml")
+	v := NewValidationError(r, ValidateStruct(form{}))
+	w := httptest.NewRecorder()
+	RespondProblem(w, r, v.StatusCode(), v)
+
+	if w.Code != http.StatusUnprocessableEntity || w.Header().Get("Content-Type") != "application/problem+xml" || w.Header().Get("Content-Language") != "en" {
+		t.Errorf("%d %s %s", w.Code, w.Header().Get("Content-Type"), w.Header().Get("Content-Language"))
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<errors>") || !strings.Contains(body, "<pointer>/name</pointer>") {
+		t.Errorf("body:\n%s", body)
+	}
+}
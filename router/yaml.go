@@ -0,0 +1,183 @@
+package router
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// YAMLEncoder renders values as YAML 1.2 block documents. Values go through
+// encoding/json first, so json struct tags and MarshalJSON methods decide the
+// output and struct fields keep their declaration order.
+var YAMLEncoder = EncoderFunc(func(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := decodeYAMLNode(dec)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	switch {
+	case node.kind == yamlMap && len(node.keys) > 0:
+		writeYAMLMap(bw, node, 0, false)
+	case node.kind == yamlList && len(node.items) > 0:
+		writeYAMLList(bw, node, 0, false)
+	default:
+		bw.WriteString(node.scalar())
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+})
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMap
+	yamlList
+)
+
+// yamlNode is a JSON value decoded with its object keys kept in order.
+type yamlNode struct {
+	kind  yamlKind
+	value interface{} // scalar: nil, bool, json.Number or string
+	keys  []string
+	items []*yamlNode // map values, parallel to keys, or list items
+}
+
+func decodeYAMLNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		node := &yamlNode{kind: yamlMap}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.keys = append(node.keys, keyTok.(string))
+			node.items = append(node.items, value)
+		}
+		_, err := dec.Token() // closing }
+		return node, err
+	case json.Delim('['):
+		node := &yamlNode{kind: yamlList}
+		for dec.More() {
+			value, err := decodeYAMLNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, value)
+		}
+		_, err := dec.Token() // closing ]
+		return node, err
+	}
+	return &yamlNode{kind: yamlScalar, value: tok}, nil
+}
+
+// isBlock reports whether n is written on its own lines rather than inline.
+func (n *yamlNode) isBlock() bool {
+	return n.kind != yamlScalar && len(n.items) > 0
+}
+
+// scalar returns the inline form of n. Empty collections are written in
+// flow style.
+func (n *yamlNode) scalar() string {
+	switch n.kind {
+	case yamlMap:
+		return "{}"
+	case yamlList:
+		return "[]"
+	}
+	switch v := n.value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	case string:
+		return yamlString(v)
+	}
+	return fmt.Sprint(n.value)
+}
+
+// writeYAMLMap writes n at indent. With inline set the first key continues
+// the current line, as it does after a list item's dash.
+func writeYAMLMap(w *bufio.Writer, n *yamlNode, indent int, inline bool) {
+	for i, key := range n.keys {
+		if i > 0 || !inline {
+			w.WriteString(strings.Repeat(" ", indent))
+		}
+		w.WriteString(yamlString(key))
+		w.WriteByte(':')
+		writeYAMLValue(w, n.items[i], indent+2)
+	}
+}
+
+func writeYAMLList(w *bufio.Writer, n *yamlNode, indent int, inline bool) {
+	for i, item := range n.items {
+		if i > 0 || !inline {
+			w.WriteString(strings.Repeat(" ", indent))
+		}
+		w.WriteString("- ")
+		switch {
+		case item.kind == yamlMap && item.isBlock():
+			writeYAMLMap(w, item, indent+2, true)
+		case item.kind == yamlList && item.isBlock():
+			writeYAMLList(w, item, indent+2, true)
+		default:
+			w.WriteString(item.scalar())
+			w.WriteByte('\n')
+		}
+	}
+}
+
+// writeYAMLValue writes the value of a mapping entry after its colon.
+func writeYAMLValue(w *bufio.Writer, n *yamlNode, indent int) {
+	switch {
+	case n.kind == yamlMap && n.isBlock():
+		w.WriteByte('\n')
+		writeYAMLMap(w, n, indent, false)
+	case n.kind == yamlList && n.isBlock():
+		w.WriteByte('\n')
+		writeYAMLList(w, n, indent, false)
+	default:
+		w.WriteByte(' ')
+		w.WriteString(n.scalar())
+		w.WriteByte('\n')
+	}
+}
+
+var (
+	yamlPlainRe    = regexp.MustCompile(`^[A-Za-z_/][A-Za-z0-9_ ./@()+-]*$`)
+	yamlReservedRe = regexp.MustCompile(`(?i)^(y|n|yes|no|true|false|on|off|null|~|\.inf|\.nan)$`)
+)
+
+// yamlString returns s as a plain scalar when that is unambiguous and as a
+// double-quoted scalar otherwise. JSON string escapes are valid in YAML.
+func yamlString(s string) string {
+	if yamlPlainRe.MatchString(s) && !yamlReservedRe.MatchString(s) && !strings.HasSuffix(s, " ") {
+		return s
+	}
+	quoted, _ := json.Marshal(s)
+	return string(quoted)
+}
@@ -0,0 +1,82 @@
+package router
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestYAMLEncoder(t *testing.T) {
+	type line struct {
+		SKU string   `json:"sku"`
+		Qty int      `json:"qty"`
+		Tag []string `json:"tags,omitempty"`
+	}
+	type order struct {
+		ID     int               `json:"id"`
+		Status string            `json:"status"`
+		Note   *string           `json:"note"`
+		Lines  []line            `json:"lines"`
+		Meta   map[string]string `json:"meta"`
+		Empty  []int             `json:"empty"`
+		Nested [][]int           `json:"nested"`
+	}
+
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{
+			"struct",
+			order{
+				ID:     7,
+				Status: "yes",
+				Lines:  []line{{SKU: "a-1", Qty: 2, Tag: []string{"x"}}, {SKU: "b: 2", Qty: 1}},
+				Meta:   map[string]string{"z": "", "a": "trailing "},
+				Empty:  []int{},
+				Nested: [][]int{{1, 2}, {}},
+			},
+			"id: 7\n" +
+				"status: \"yes\"\n" +
+				"note: null\n" +
+				"lines:\n" +
+				"  - sku: a-1\n" +
+				"    qty: 2\n" +
+				"    tags:\n" +
+				"      - x\n" +
+				"  - sku: \"b: 2\"\n" +
+				"    qty: 1\n" +
+				"meta:\n" +
+				"  a: \"trailing \"\n" +
+				"  z: \"\"\n" +
+				"empty: []\n" +
+				"nested:\n" +
+				"  - - 1\n" +
+				"    - 2\n" +
+				"  - []\n",
+		},
+		{"string", "hello world", "hello world\n"},
+		{"reserved", "off", "\"off\"\n"},
+		{"number-like", "1e3", "\"1e3\"\n"},
+		{"multiline", "a\nb", "\"a\\nb\"\n"},
+		{"float", 1.5, "1.5\n"},
+		{"nil", nil, "null\n"},
+		{"empty map", map[string]int{}, "{}\n"},
+		{"list", []interface{}{true, "~", map[string]int{}}, "- true\n- \"~\"\n- {}\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := YAMLEncoder.Encode(&buf, tt.v); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, buf.String(), tt.want)
+		}
+	}
+
+	if err := YAMLEncoder.Encode(&bytes.Buffer{}, func() {}); err == nil {
+		t.Error("encoding a func succeeded")
+	}
+}